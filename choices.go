@@ -0,0 +1,75 @@
+package structcli
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/tsingmuhe/structcli/flag"
+)
+
+// Chooser is implemented by option and positional field types, or their
+// element type for slices and maps, whose values are restricted to a fixed
+// set. The choices tag takes precedence over it.
+type Chooser interface {
+	Choices() []string
+}
+
+// getChoices returns the values allowed for the field, from its choices tag
+// or its type.
+func getChoices(sf *structField) []string {
+	if choices := sf.getChoices(); len(choices) > 0 {
+		return choices
+	}
+
+	t, _ := sf.indirectType()
+	for _, t := range []reflect.Type{t, sf.elemType()} {
+		if chooser, ok := reflect.New(t).Interface().(Chooser); ok {
+			return chooser.Choices()
+		}
+	}
+	return nil
+}
+
+// validateChoices checks that every choice is a valid value for the field.
+func validateChoices(sf *structField, choices []string) error {
+	for _, choice := range choices {
+		value := newValue(reflect.New(sf.elemType()))
+		if value == nil {
+			return nil
+		}
+
+		err := value.Set(choice)
+		if err != nil {
+			return fmt.Errorf("invalid choice %q for field `%s`: %w", choice, sf.Name, err)
+		}
+	}
+	return nil
+}
+
+// choiceValue only accepts the values listed in choices.
+type choiceValue struct {
+	flag.Value
+	choices []string
+}
+
+func (c choiceValue) Set(s string) error {
+	if !slices.Contains(c.choices, s) {
+		return fmt.Errorf("%q is not one of %s", s, strings.Join(c.choices, ", "))
+	}
+	return c.Value.Set(s)
+}
+
+func (c choiceValue) IsBool() bool {
+	return isBoolValue(c.Value)
+}
+
+// choiceCompletions returns the choices as completions.
+func choiceCompletions(choices []string) []Completion {
+	completions := make([]Completion, len(choices))
+	for i, choice := range choices {
+		completions[i] = Completion{Value: choice}
+	}
+	return completions
+}
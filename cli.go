@@ -1,8 +1,36 @@
 package structcli
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"os"
 	"reflect"
+	"text/template"
+
+	"github.com/tsingmuhe/structcli/flag"
+)
+
+// ErrHelp is returned by Parse and Execute after printing the help requested
+// with -h or --help.
+var ErrHelp = flag.ErrHelp
+
+// ErrorHandling defines how Parse and Execute behave when they fail.
+type ErrorHandling = flag.ErrorHandling
+
+const (
+	// ContinueOnError returns the error.
+	ContinueOnError = flag.ContinueOnError
+	// ExitOnError prints the error, and the usage for a usage error, then
+	// exits with status 2 for a usage error and 1 otherwise. It exits with
+	// status 0 after printing the help, the version or the completions. The
+	// errors returned by Runner.Run are returned unchanged.
+	ExitOnError = flag.ExitOnError
+	// PanicOnError panics with the error. ErrHelp, ErrVersion and
+	// ErrCompletion are still returned, and so are the errors returned by
+	// Runner.Run.
+	PanicOnError = flag.PanicOnError
 )
 
 type CommandLine[T any] struct {
@@ -10,27 +38,224 @@ type CommandLine[T any] struct {
 	description string
 	version     string
 
+	versionShorthand bool
+	versionTemplate  string
+	envPrefix        string
+	configOption     string
+	configFormat     ConfigFormat
+
+	completions map[string]CompletionFunc
+
+	errorHandling ErrorHandling
+	output        io.Writer
+	errOutput     io.Writer
+	exit          func(code int)
+
 	command     *T
 	commandSpec *commandSpec
 }
 
-func (c *CommandLine[T]) Parse(args []string) (*T, error) {
+// SetEnvPrefix sets a prefix prepended to the environment variable names
+// declared with the env tag, e.g. "APP_".
+func (c *CommandLine[T]) SetEnvPrefix(prefix string) {
+	c.envPrefix = prefix
+}
+
+// SetConfig loads option values from the configuration file whose path is
+// given by the root option named option, e.g. "--config". Values are merged
+// below environment variables and command line arguments. A nil format
+// selects JSONConfig for .json files and INIConfig otherwise.
+func (c *CommandLine[T]) SetConfig(option string, format ConfigFormat) {
+	c.configOption = option
+	c.configFormat = format
+}
+
+// SetErrorHandling sets how Parse and Execute behave when parsing fails. The
+// default is ContinueOnError.
+func (c *CommandLine[T]) SetErrorHandling(errorHandling ErrorHandling) {
+	c.errorHandling = errorHandling
+}
+
+// SetOutput sets the destination of the help and the version. If w is nil,
+// os.Stdout is used.
+func (c *CommandLine[T]) SetOutput(w io.Writer) {
+	c.output = w
+}
+
+// SetErrOutput sets the destination of the errors printed under ExitOnError.
+// If w is nil, os.Stderr is used.
+func (c *CommandLine[T]) SetErrOutput(w io.Writer) {
+	c.errOutput = w
+}
+
+// SetExitFunc sets the function called to exit under ExitOnError, os.Exit by
+// default.
+func (c *CommandLine[T]) SetExitFunc(exit func(code int)) {
+	c.exit = exit
+}
+
+// SetVersionShorthand enables -V as a shorthand for --version.
+func (c *CommandLine[T]) SetVersionShorthand(enabled bool) {
+	c.versionShorthand = enabled
+}
+
+// SetVersionTemplate sets the text/template used to print the version, which
+// is executed with a *VersionInfo.
+func (c *CommandLine[T]) SetVersionTemplate(tmpl string) {
+	c.versionTemplate = tmpl
+}
+
+func (c *CommandLine[T]) Parse(args []string) (*Result[T], error) {
+	selected, err := c.parse(args)
+	if err != nil {
+		return nil, c.handleError(selected, err)
+	}
+
+	return &Result[T]{
+		Command:  c.command,
+		Path:     selected.path(),
+		Settings: selected.settings(),
+	}, nil
+}
+
+// Execute parses args and runs the deepest selected command, which must
+// implement Runner.
+func (c *CommandLine[T]) Execute(ctx context.Context, args []string) error {
+	selected, err := c.parse(args)
+	if err != nil {
+		return c.handleError(selected, err)
+	}
+
+	return selected.run(ctx)
+}
+
+// handleError applies the error handling to err. selected is the command the
+// error occurred in, if any.
+func (c *CommandLine[T]) handleError(selected *commandSpec, err error) error {
+	requested := errors.Is(err, ErrHelp) || errors.Is(err, ErrVersion) || errors.Is(err, ErrCompletion)
+
+	switch c.errorHandling {
+	case ExitOnError:
+		code := 0
+		if !requested {
+			code = 1
+			if isUsageError(err) {
+				code = 2
+			}
+			_ = selected.writeError(c.getErrOutput(), err)
+		}
+
+		if c.exit == nil {
+			os.Exit(code)
+		}
+		c.exit(code)
+	case PanicOnError:
+		if !requested {
+			panic(err)
+		}
+	}
+	return err
+}
+
+func (c *CommandLine[T]) getOutput() io.Writer {
+	if c.output == nil {
+		return os.Stdout
+	}
+	return c.output
+}
+
+func (c *CommandLine[T]) getErrOutput() io.Writer {
+	if c.errOutput == nil {
+		return os.Stderr
+	}
+	return c.errOutput
+}
+
+// parse returns the deepest selected command, which is also returned along
+// with an error once the command spec has been built.
+func (c *CommandLine[T]) parse(args []string) (*commandSpec, error) {
 	err := c.parseCommandSpec()
 	if err != nil {
 		return nil, err
 	}
 
-	return nil, nil
+	versionTemplate, err := template.New("version").Parse(c.versionTemplate)
+	if err != nil {
+		return nil, &SpecError{Command: []string{c.name}, Err: fmt.Errorf("invalid version template: %w", err)}
+	}
+
+	if c.isCompletion(args) {
+		err = c.complete(c.getOutput(), args[1:])
+		if err != nil {
+			return c.commandSpec, err
+		}
+		return c.commandSpec, ErrCompletion
+	}
+
+	selected, err := c.commandSpec.parse(args)
+	switch {
+	case errors.Is(err, ErrHelp):
+		writeErr := selected.writeHelp(c.getOutput(), terminalWidth())
+		if writeErr != nil {
+			return selected, writeErr
+		}
+	case errors.Is(err, ErrVersion):
+		writeErr := writeVersion(c.getOutput(), versionTemplate, newVersionInfo(c.name, c.version))
+		if writeErr != nil {
+			return selected, writeErr
+		}
+	}
+
+	if err != nil {
+		return selected, err
+	}
+
+	err = c.resolveOptions(selected)
+	if err != nil {
+		return selected, err
+	}
+	return selected, nil
+}
+
+func (c *CommandLine[T]) resolveOptions(selected *commandSpec) error {
+	var config *Config
+	if c.configOption != "" {
+		var err error
+		config, err = c.commandSpec.loadConfig(c.configOption, c.configFormat)
+		if err != nil {
+			return err
+		}
+	}
+
+	for i, cmd := range selected.chain() {
+		if i > 0 {
+			config = config.section(cmd.name)
+		}
+
+		err := cmd.resolveOptions(config)
+		if err != nil {
+			return err
+		}
+	}
+
+	var errs []*InvalidValueError
+	for _, cmd := range selected.chain() {
+		errs = append(errs, cmd.validate()...)
+	}
+	if len(errs) > 0 {
+		return &ValidationError{Errors: errs}
+	}
+	return nil
 }
 
 func (c *CommandLine[T]) parseCommandSpec() error {
 	rv := reflect.ValueOf(c.command)
-	if rv.Elem().Kind() != reflect.Struct {
-		return fmt.Errorf("command must be a struct, got %s", rv.Type().String())
+	if rv.IsNil() {
+		return &SpecError{Command: []string{c.name}, Err: errors.New("command must not be nil")}
 	}
 
-	if rv.IsNil() {
-		return fmt.Errorf("command must not be nil")
+	if rv.Elem().Kind() != reflect.Struct {
+		return &SpecError{Command: []string{c.name}, Err: fmt.Errorf("command must be a struct, got %s", rv.Type().String())}
 	}
 
 	spec := &commandSpec{
@@ -43,6 +268,12 @@ func (c *CommandLine[T]) parseCommandSpec() error {
 		return err
 	}
 
+	if c.version != "" {
+		spec.registerVersion(c.versionShorthand)
+	}
+
+	spec.applyEnvPrefix(c.envPrefix)
+
 	c.commandSpec = spec
 	return nil
 }
@@ -52,6 +283,9 @@ func Create[T any](name, description, version string, command *T) *CommandLine[T
 		name:        name,
 		description: description,
 		version:     version,
-		command:     command,
+
+		versionTemplate: DefaultVersionTemplate,
+
+		command: command,
 	}
 }
@@ -1,32 +1,39 @@
 package structcli_test
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"os"
+	"log/slog"
+	"math/big"
+	"net/netip"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/tsingmuhe/structcli"
 )
 
 type MainCommand struct {
-	Hello1 string  `short:"-h" long:"--hello" placeholder:"" description:"Hello"  `
-	Hello2 *string `short:"-h" long:"--hello" placeholder:"" description:"Hello"  `
-	Hello3 bool    `short:"-h" long:"--hello,negatable" description:"Hello"`
-	Hello4 *bool   `short:"-h" long:"--hello,negatable" description:"Hello"`
+	Hello1 string  `short:"-a" long:"--hello1" placeholder:"" description:"Hello" required:"true"`
+	Hello2 *string `short:"-b" long:"--hello2" placeholder:"" description:"Hello"  `
+	Hello3 bool    `short:"-c" long:"--hello3,negatable" description:"Hello"`
+	Hello4 *bool   `short:"-d" long:"--hello4,negatable" description:"Hello"`
 
-	World1 string   `description:"Hello" placeholder:""`
+	World1 string   `description:"Hello" placeholder:"" required:"true"`
 	World2 *string  `description:"Hello" placeholder:""`
 	World3 []string `description:"Hello" placeholder:""`
 
-	SubCommand *SubCommand `command:"sub" description:"sub command"`
+	SubCommand   *SubCommand `command:"sub" description:"sub command"`
+	OtherCommand *SubCommand `command:"other" description:"other command"`
 }
 
 type SubCommand struct {
-	Hello1 string  `short:"-h" long:"--hello" description:"Hello" placeholder:""`
-	Hello2 *string `short:"-h" long:"--hello" description:"Hello" placeholder:""`
-	Hello3 bool    `short:"-h" long:"--hello,negatable" description:"Hello"`
-	Hello4 *bool   `short:"-h" long:"--hello,negatable" description:"Hello"`
+	Hello1 string  `short:"-a" long:"--hello1" description:"Hello" placeholder:""`
+	Hello2 *string `short:"-b" long:"--hello2" description:"Hello" placeholder:""`
+	Hello3 bool    `short:"-c" long:"--hello3,negatable" description:"Hello"`
+	Hello4 *bool   `short:"-d" long:"--hello4,negatable" description:"Hello"`
 
 	World1 string   `description:"Hello" placeholder:""`
 	World2 *string  `description:"Hello" placeholder:""`
@@ -35,10 +42,765 @@ type SubCommand struct {
 
 func TestCommandLine_Create(t *testing.T) {
 	cli := structcli.Create("cmd", "a test cmd", "1.0.0", new(MainCommand))
-	cmd, err := cli.Parse(os.Args[1:])
+	res, err := cli.Parse([]string{"--hello1", "x", "w1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reflect.TypeOf(res.Command) != reflect.TypeOf(&MainCommand{}) {
+		t.Fatalf("unexpected command type %T", res.Command)
+	}
+}
+
+func TestCommandLine_Parse(t *testing.T) {
+	cli := structcli.Create("cmd", "a test cmd", "1.0.0", new(MainCommand))
+	res, err := cli.Parse([]string{"-a", "x", "--hello2=y", "-cd", "--no-hello4", "w1", "w2", "w3", "--", "-w4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := res.Command
+	if cmd.Hello1 != "x" || cmd.Hello2 == nil || *cmd.Hello2 != "y" {
+		t.Fatalf("unexpected string options: %q %v", cmd.Hello1, cmd.Hello2)
+	}
+
+	if !cmd.Hello3 || cmd.Hello4 == nil || *cmd.Hello4 {
+		t.Fatalf("unexpected bool options: %v %v", cmd.Hello3, cmd.Hello4)
+	}
+
+	if cmd.World1 != "w1" || cmd.World2 == nil || *cmd.World2 != "w2" || !reflect.DeepEqual(cmd.World3, []string{"w3", "-w4"}) {
+		t.Fatalf("unexpected positionals: %q %v %q", cmd.World1, cmd.World2, cmd.World3)
+	}
+
+	if cmd.SubCommand != nil || cmd.OtherCommand != nil {
+		t.Fatalf("subcommands must stay nil when not selected")
+	}
+
+	if res.Selected() != "cmd" {
+		t.Fatalf("unexpected selected command %q", res.Selected())
+	}
+}
+
+func TestCommandLine_ParseSubcommand(t *testing.T) {
+	cli := structcli.Create("cmd", "a test cmd", "1.0.0", new(MainCommand))
+	res, err := cli.Parse([]string{"-a", "x", "sub", "-a", "y", "w1", "w2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := res.Command
+	if cmd.Hello1 != "x" || cmd.World1 != "" {
+		t.Fatalf("unexpected root fields: %q %q", cmd.Hello1, cmd.World1)
+	}
+
+	if cmd.SubCommand == nil || cmd.OtherCommand != nil {
+		t.Fatalf("only the selected subcommand must be allocated")
+	}
+
+	if cmd.SubCommand.Hello1 != "y" || cmd.SubCommand.World1 != "w1" || *cmd.SubCommand.World2 != "w2" {
+		t.Fatalf("unexpected subcommand fields: %+v", cmd.SubCommand)
+	}
+
+	if !reflect.DeepEqual(res.Path, []string{"cmd", "sub"}) || res.Selected() != "cmd sub" {
+		t.Fatalf("unexpected path %q", res.Path)
+	}
+}
+
+func TestCommandLine_ParseOptional(t *testing.T) {
+	cli := structcli.Create("cmd", "a test cmd", "1.0.0", new(MainCommand))
+	res, err := cli.Parse([]string{"w1", "--hello1=x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := res.Command
+	if cmd.Hello2 != nil || cmd.Hello4 != nil || cmd.World2 != nil || cmd.World3 != nil {
+		t.Fatalf("absent optional fields must stay nil")
+	}
+}
+
+func TestCommandLine_ParseErrors(t *testing.T) {
+	tests := [][]string{
+		{"w1"},
+		{"--hello1"},
+		{"--hello1=x", "--unknown", "w1"},
+		{"--hello1=x", "--no-hello4=true", "w1"},
+		{"--hello1=x", "--hello3=maybe", "w1"},
+		{"--hello1=x"},
+	}
+
+	for _, args := range tests {
+		cli := structcli.Create("cmd", "a test cmd", "1.0.0", new(MainCommand))
+		_, err := cli.Parse(args)
+		if err == nil {
+			t.Errorf("expected error for %q", args)
+		}
+	}
+}
+
+func TestCommandLine_ParseErrorTypes(t *testing.T) {
+	parse := func(args ...string) error {
+		_, err := structcli.Create("cmd", "a test cmd", "1.0.0", new(MainCommand)).Parse(args)
+		return err
+	}
+
+	var unknownErr *structcli.UnknownOptionError
+	err := parse("-a", "x", "sub", "--unknown")
+	if !errors.As(err, &unknownErr) || unknownErr.Option != "--unknown" || !reflect.DeepEqual(unknownErr.Command, []string{"cmd", "sub"}) {
+		t.Errorf("expected an unknown option error, got %v", err)
+	}
+
+	var missingErr *structcli.MissingArgumentError
+	err = parse("w1", "--hello1")
+	if !errors.As(err, &missingErr) || missingErr.Option != "--hello1" {
+		t.Errorf("expected a missing argument error, got %v", err)
+	}
+
+	var invalidErr *structcli.InvalidValueError
+	err = parse("-a", "x", "-c=maybe", "w1")
+	if !errors.As(err, &invalidErr) || invalidErr.Name != "-c" || invalidErr.Value != "maybe" || invalidErr.Origin.Source != structcli.SourceArgs {
+		t.Errorf("expected an invalid value error, got %v", err)
+	}
+
+	var requiredErr *structcli.MissingRequiredError
+	err = parse("w1")
+	if !errors.As(err, &requiredErr) || requiredErr.Name != "--hello1" {
+		t.Errorf("expected a missing required error, got %v", err)
+	}
+
+	var commandErr *structcli.UnknownCommandError
+	err = parseNew[struct {
+		Sub *SubCommand `command:"sub"`
+	}]("depoly")
+	if !errors.As(err, &commandErr) || commandErr.Name != "depoly" || !reflect.DeepEqual(commandErr.Command, []string{"cmd"}) {
+		t.Errorf("expected an unknown command error, got %v", err)
+	}
+
+	var specErr *structcli.SpecError
+	err = parseNew[struct {
+		Sub *struct {
+			Port int `long:"port"`
+		} `command:"sub"`
+	}]()
+	if !errors.As(err, &specErr) || specErr.Field != "Port" || !reflect.DeepEqual(specErr.Command, []string{"cmd", "sub"}) {
+		t.Errorf("expected a spec error, got %v", err)
+	}
+}
+
+func TestCommandLine_Suggestions(t *testing.T) {
+	cli := structcli.Create("cmd", "a test cmd", "1.0.0", new(MainCommand))
+	_, err := cli.Parse([]string{"-a", "x", "--helo1=y", "w1"})
+	want := "unknown option `--helo1`, did you mean `--hello1`?"
+	if err == nil || err.Error() != want {
+		t.Errorf("expected %q, got %v", want, err)
+	}
+
+	_, err = structcli.Create("cmd", "a test cmd", "1.0.0", new(RunCommand)).Parse([]string{"deploy", "--verbsoe", "prod"})
+
+	var optionErr *structcli.UnknownOptionError
+	if !errors.As(err, &optionErr) || len(optionErr.Suggestions) != 1 || !reflect.DeepEqual(optionErr.Suggestions[0], structcli.Suggestion{Name: "--verbose", Command: []string{"cmd"}}) {
+		t.Fatalf("expected a suggestion from the parent command, got %v", err)
+	}
+
+	want = "unknown option `--verbsoe`, did you mean `--verbose` of command `cmd`?"
+	if err.Error() != want {
+		t.Errorf("expected %q, got %q", want, err)
+	}
+
+	_, err = structcli.Create("cmd", "a test cmd", "1.0.0", new(RunCommand)).Parse([]string{"depoly"})
+	want = "unknown command `depoly`, did you mean `deploy`?"
+	if err == nil || err.Error() != want {
+		t.Errorf("expected %q, got %v", want, err)
+	}
+}
+
+func TestCommandLine_SetErrorHandling(t *testing.T) {
+	var out, errOut strings.Builder
+	var codes []int
+
+	newCLI := func() *structcli.CommandLine[MainCommand] {
+		cli := structcli.Create("cmd", "a test cmd", "1.0.0", new(MainCommand))
+		cli.SetErrorHandling(structcli.ExitOnError)
+		cli.SetOutput(&out)
+		cli.SetErrOutput(&errOut)
+		cli.SetExitFunc(func(code int) { codes = append(codes, code) })
+		return cli
+	}
+
+	_, err := newCLI().Parse([]string{"-a", "x", "sub", "--unknown"})
+	if err == nil {
+		t.Fatal("expected error for an unknown option")
+	}
+
+	want := "error: unknown option `--unknown`\n\nUsage: cmd sub [OPTIONS] [World1] [World2] [World3]...\n\nFor more information, try '--help'.\n"
+	if errOut.String() != want {
+		t.Errorf("unexpected error output:\n%s\nwant:\n%s", errOut.String(), want)
+	}
+
+	_, _ = newCLI().Parse([]string{"--version"})
+	if out.String() != "cmd 1.0.0\n" {
+		t.Errorf("unexpected output %q", out.String())
+	}
+
+	if !reflect.DeepEqual(codes, []int{2, 0}) {
+		t.Errorf("unexpected exit codes %v", codes)
+	}
+
+	defer func() {
+		if _, ok := recover().(*structcli.UnknownOptionError); !ok {
+			t.Error("expected a panic with the error")
+		}
+	}()
+
+	cli := structcli.Create("cmd", "a test cmd", "1.0.0", new(MainCommand))
+	cli.SetErrorHandling(structcli.PanicOnError)
+	_, _ = cli.Parse([]string{"--unknown"})
+}
+
+type RunCommand struct {
+	Verbose bool `short:"-v" long:"--verbose" description:"Verbose output"`
+
+	Deploy *DeployCommand `command:"deploy" description:"deploy command"`
+}
+
+type DeployCommand struct {
+	Target string `description:"Deploy target"`
+
+	ran bool
+}
+
+func (d *DeployCommand) Run(ctx context.Context) error {
+	root, ok := structcli.Parent[RunCommand](ctx)
+	if !ok || !root.Verbose {
+		return errors.New("parent command is not reachable")
+	}
+
+	d.ran = true
+	return nil
+}
+
+func TestCommandLine_Execute(t *testing.T) {
+	cmd := new(RunCommand)
+	cli := structcli.Create("cmd", "a test cmd", "1.0.0", cmd)
+	err := cli.Execute(context.Background(), []string{"-v", "deploy", "prod"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cmd.Deploy == nil || !cmd.Deploy.ran || cmd.Deploy.Target != "prod" {
+		t.Fatalf("deploy command was not run: %+v", cmd.Deploy)
+	}
+
+	err = structcli.Create("cmd", "a test cmd", "1.0.0", new(RunCommand)).Execute(context.Background(), []string{"-v"})
+	var specErr *structcli.SpecError
+	if !errors.As(err, &specErr) || !reflect.DeepEqual(specErr.Command, []string{"cmd"}) {
+		t.Fatalf("expected SpecError for a command that cannot be run, got %v", err)
+	}
+
+	for _, errorHandling := range []structcli.ErrorHandling{structcli.ExitOnError, structcli.PanicOnError} {
+		cli := structcli.Create("cmd", "a test cmd", "1.0.0", new(RunCommand))
+		cli.SetErrorHandling(errorHandling)
+		cli.SetExitFunc(func(code int) { t.Errorf("unexpected exit with status %d", code) })
+
+		err = cli.Execute(context.Background(), []string{"deploy"})
+		if err == nil || err.Error() != "parent command is not reachable" {
+			t.Errorf("expected the error returned by Run, got %v", err)
+		}
+	}
+}
+
+type Mode int8
+
+type NumericCommand struct {
+	Int     int      `long:"--int"`
+	Int8    Mode     `long:"--int8"`
+	Int16   *int16   `long:"--int16"`
+	Uint8   uint8    `short:"-u"`
+	Uint64  *uint64  `long:"--uint64"`
+	Float32 float32  `long:"--float32"`
+	Float64 *float64 `long:"--float64"`
+
+	Ports []uint16 `placeholder:"PORT"`
+}
+
+func TestCommandLine_ParseNumeric(t *testing.T) {
+	cli := structcli.Create("cmd", "a test cmd", "1.0.0", new(NumericCommand))
+	res, err := cli.Parse([]string{"--int=-1", "--int8", "0x10", "--int16=300", "-u", "255", "--uint64=1", "--float32=1.5", "--float64=2.5", "80", "443"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := res.Command
+	if cmd.Int != -1 || cmd.Int8 != 16 || *cmd.Int16 != 300 || cmd.Uint8 != 255 || *cmd.Uint64 != 1 || cmd.Float32 != 1.5 || *cmd.Float64 != 2.5 {
+		t.Fatalf("unexpected numeric options: %+v", cmd)
+	}
+
+	if !reflect.DeepEqual(cmd.Ports, []uint16{80, 443}) {
+		t.Fatalf("unexpected positionals: %v", cmd.Ports)
+	}
+
+	tests := map[string][]string{
+		"value out of range": {"--int=1", "--int8=128", "-u", "1", "--float32=1"},
+		"parse error":        {"--int=1", "--int8=1", "-u", "x", "--float32=1"},
+	}
+
+	for want, args := range tests {
+		_, err = structcli.Create("cmd", "a test cmd", "1.0.0", new(NumericCommand)).Parse(args)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q error for %q, got %v", want, args, err)
+		}
+	}
+}
+
+type TextCommand struct {
+	Addr  netip.Addr  `long:"--addr"`
+	Level *slog.Level `long:"--level"`
+	Size  *big.Int    `long:"--size"`
+
+	Peers []netip.Addr `placeholder:"PEER"`
+}
+
+func TestCommandLine_ParseText(t *testing.T) {
+	cli := structcli.Create("cmd", "a test cmd", "1.0.0", new(TextCommand))
+	res, err := cli.Parse([]string{"--addr=127.0.0.1", "--level", "warn", "--size=123456789012345678901234567890", "::1", "10.0.0.1"})
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	fmt.Println(reflect.ValueOf(cmd).Type())
+	cmd := res.Command
+	if cmd.Addr != netip.MustParseAddr("127.0.0.1") || *cmd.Level != slog.LevelWarn || cmd.Size.String() != "123456789012345678901234567890" {
+		t.Fatalf("unexpected text options: %+v", cmd)
+	}
+
+	if !reflect.DeepEqual(cmd.Peers, []netip.Addr{netip.MustParseAddr("::1"), netip.MustParseAddr("10.0.0.1")}) {
+		t.Fatalf("unexpected positionals: %v", cmd.Peers)
+	}
+
+	_, err = structcli.Create("cmd", "a test cmd", "1.0.0", new(TextCommand)).Parse([]string{"--addr=localhost"})
+	if err == nil {
+		t.Fatal("expected error for an invalid address")
+	}
+}
+
+type Selector map[string]string
+
+func (s Selector) String() string { return fmt.Sprint(map[string]string(s)) }
+
+func (s Selector) Set(val string) error {
+	key, value, ok := strings.Cut(val, "=")
+	if !ok {
+		return errors.New("expected key=value")
+	}
+	s[key] = value
+	return nil
+}
+
+type Switch struct{ on int }
+
+func (s *Switch) String() string { return fmt.Sprint(s.on) }
+
+func (s *Switch) Set(val string) error {
+	s.on++
+	return nil
+}
+
+func (s *Switch) IsBool() bool { return true }
+
+type List struct {
+	sep   string
+	items []string
+}
+
+func (l *List) String() string { return strings.Join(l.items, l.sep) }
+
+func (l *List) Set(val string) error {
+	l.items = append(l.items, val)
+	return nil
+}
+
+type ValueCommand struct {
+	Selector Selector `short:"-l" long:"--selector"`
+	Switch   *Switch  `short:"-s" long:"--switch"`
+	List     *List    `long:"--list"`
+}
+
+func TestCommandLine_ParseValue(t *testing.T) {
+	cmd := &ValueCommand{Selector: Selector{}}
+	res, err := structcli.Create("cmd", "a test cmd", "1.0.0", cmd).Parse([]string{"-l", "env=prod", "-s", "--selector=team=core"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(res.Command.Selector, Selector{"env": "prod", "team": "core"}) || res.Command.Switch == nil || res.Command.Switch.on != 1 {
+		t.Fatalf("unexpected custom values: %+v", res.Command)
+	}
+
+	res, err = structcli.Create("cmd", "a test cmd", "1.0.0", &ValueCommand{List: &List{sep: ";"}}).Parse([]string{"-s", "-s", "-s", "--list=a", "--list=b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Command.Switch.on != 3 || res.Command.List.String() != "a;b" {
+		t.Fatalf("repeated values must be set on the same instance: %+v", res.Command)
+	}
+
+	_, err = structcli.Create("cmd", "a test cmd", "1.0.0", &ValueCommand{Selector: Selector{}}).Parse([]string{"-l", "env"})
+	if err == nil || !strings.Contains(err.Error(), "expected key=value") {
+		t.Fatalf("expected error from Set, got %v", err)
+	}
+}
+
+type SliceCommand struct {
+	Tags  []string     `short:"-t" long:"--tag" separator:","`
+	Ports []int        `long:"--port"`
+	Addrs []netip.Addr `long:"--addr"`
+}
+
+func TestCommandLine_ParseSlice(t *testing.T) {
+	cmd := &SliceCommand{Tags: []string{"default"}}
+	res, err := structcli.Create("cmd", "a test cmd", "1.0.0", cmd).Parse([]string{"-t", "a", "--tag=b,c", "--port=1", "--port", "2", "--addr=::1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmd = res.Command
+	if !reflect.DeepEqual(cmd.Tags, []string{"a", "b", "c"}) || !reflect.DeepEqual(cmd.Ports, []int{1, 2}) || !reflect.DeepEqual(cmd.Addrs, []netip.Addr{netip.MustParseAddr("::1")}) {
+		t.Fatalf("unexpected slice options: %+v", cmd)
+	}
+
+	cmd = &SliceCommand{Tags: []string{"default"}}
+	res, err = structcli.Create("cmd", "a test cmd", "1.0.0", cmd).Parse(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(res.Command.Tags, []string{"default"}) || res.Command.Ports != nil {
+		t.Fatalf("absent slice options must keep their initial value: %+v", res.Command)
+	}
+}
+
+type MapCommand struct {
+	Labels  map[string]string `short:"-l" long:"--label"`
+	Weights map[string]int    `long:"--weight" separator:"," duplicate:"error"`
+}
+
+func TestCommandLine_ParseMap(t *testing.T) {
+	res, err := structcli.Create("cmd", "a test cmd", "1.0.0", new(MapCommand)).Parse([]string{"-l", "env=prod", "--label=team=core", "-l", "env=dev", "--weight=a=1,b=2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := res.Command
+	if !reflect.DeepEqual(cmd.Labels, map[string]string{"env": "dev", "team": "core"}) || !reflect.DeepEqual(cmd.Weights, map[string]int{"a": 1, "b": 2}) {
+		t.Fatalf("unexpected map options: %+v", cmd)
+	}
+
+	tests := [][]string{
+		{"--label=env"},
+		{"--weight=a=1", "--weight=a=2"},
+		{"--weight=a=x"},
+	}
+
+	for _, args := range tests {
+		_, err = structcli.Create("cmd", "a test cmd", "1.0.0", new(MapCommand)).Parse(args)
+		if err == nil {
+			t.Errorf("expected error for %q", args)
+		}
+	}
+}
+
+type TimeCommand struct {
+	Timeout  time.Duration   `long:"--timeout"`
+	Backoff  []time.Duration `long:"--backoff" separator:","`
+	Since    *time.Time      `long:"--since"`
+	Deadline time.Time       `long:"--deadline" layout:"2006-01-02 15:04"`
+}
+
+func TestCommandLine_ParseTime(t *testing.T) {
+	res, err := structcli.Create("cmd", "a test cmd", "1.0.0", new(TimeCommand)).Parse([]string{"--timeout=1m30s", "--backoff=1s,2s", "--since=2024-05-01", "--deadline", "2024-05-02 10:30"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := res.Command
+	if cmd.Timeout != 90*time.Second || !reflect.DeepEqual(cmd.Backoff, []time.Duration{time.Second, 2 * time.Second}) {
+		t.Fatalf("unexpected durations: %+v", cmd)
+	}
+
+	if !cmd.Since.Equal(time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)) || !cmd.Deadline.Equal(time.Date(2024, 5, 2, 10, 30, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected times: %+v", cmd)
+	}
+
+	tests := map[string][]string{
+		"`--timeout`: expected a duration":             {"--timeout=10", "--deadline=2024-05-02 10:30"},
+		"`--deadline`: expected a time in format 2006": {"--deadline=2024-05-02"},
+	}
+
+	for want, args := range tests {
+		_, err = structcli.Create("cmd", "a test cmd", "1.0.0", new(TimeCommand)).Parse(args)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q error for %q, got %v", want, args, err)
+		}
+	}
+}
+
+type EnvCommand struct {
+	Port    int      `long:"--port" env:"PORT"`
+	Verbose bool     `long:"--verbose,negatable" env:"VERBOSE"`
+	Tags    []string `long:"--tag" env:"TAGS" separator:","`
+
+	Sub *EnvSubCommand `command:"sub"`
+}
+
+type EnvSubCommand struct {
+	Token *string `long:"--token" env:"TOKEN"`
+}
+
+func TestCommandLine_ParseEnv(t *testing.T) {
+	t.Setenv("APP_PORT", "8080")
+	t.Setenv("APP_VERBOSE", "true")
+	t.Setenv("APP_TAGS", "a,b")
+	t.Setenv("APP_TOKEN", "secret")
+
+	cli := structcli.Create("cmd", "a test cmd", "1.0.0", new(EnvCommand))
+	cli.SetEnvPrefix("APP_")
+
+	res, err := cli.Parse([]string{"--no-verbose", "--tag=c", "sub"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := res.Command
+	if cmd.Port != 8080 || cmd.Verbose || !reflect.DeepEqual(cmd.Tags, []string{"c"}) || *cmd.Sub.Token != "secret" {
+		t.Fatalf("unexpected env options: %+v %+v", cmd, cmd.Sub)
+	}
+
+	t.Setenv("APP_PORT", "http")
+
+	cli = structcli.Create("cmd", "a test cmd", "1.0.0", new(EnvCommand))
+	cli.SetEnvPrefix("APP_")
+
+	_, err = cli.Parse(nil)
+	if err == nil || !strings.Contains(err.Error(), "APP_PORT") {
+		t.Fatalf("expected error naming the environment variable, got %v", err)
+	}
+
+	res, err = cli.Parse([]string{"--port=1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Command.Port != 1 || !res.Command.Verbose || !reflect.DeepEqual(res.Command.Tags, []string{"a", "b"}) {
+		t.Fatalf("unexpected env options: %+v", res.Command)
+	}
+}
+
+type DefaultCommand struct {
+	Port    int           `long:"--port" env:"DEFAULT_PORT" default:"8080"`
+	Timeout time.Duration `long:"--timeout" default:"30s"`
+	Verbose bool          `long:"--verbose,negatable" default:"true"`
+	Tags    []string      `long:"--tag" separator:"," default:"a,b"`
+	Name    string        `long:"--name" required:"true"`
+
+	Target string `default:"local"`
+}
+
+func TestCommandLine_ParseDefault(t *testing.T) {
+	res, err := structcli.Create("cmd", "a test cmd", "1.0.0", new(DefaultCommand)).Parse([]string{"--name=x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := res.Command
+	if cmd.Port != 8080 || cmd.Timeout != 30*time.Second || !cmd.Verbose || !reflect.DeepEqual(cmd.Tags, []string{"a", "b"}) || cmd.Target != "local" {
+		t.Fatalf("unexpected defaults: %+v", cmd)
+	}
+
+	t.Setenv("DEFAULT_PORT", "9090")
+
+	res, err = structcli.Create("cmd", "a test cmd", "1.0.0", new(DefaultCommand)).Parse([]string{"--name=x", "--no-verbose", "--tag=c", "remote"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmd = res.Command
+	if cmd.Port != 9090 || cmd.Verbose || !reflect.DeepEqual(cmd.Tags, []string{"c"}) || cmd.Target != "remote" {
+		t.Fatalf("defaults must not override env and argv: %+v", cmd)
+	}
+
+	_, err = structcli.Create("cmd", "a test cmd", "1.0.0", new(DefaultCommand)).Parse(nil)
+	if err == nil || !strings.Contains(err.Error(), "--name") {
+		t.Fatalf("expected missing required option error, got %v", err)
+	}
+}
+
+type Level string
+
+func (l Level) Choices() []string {
+	return []string{"debug", "info", "warn"}
+}
+
+type ChoiceCommand struct {
+	Format string   `long:"--format" placeholder:"FORMAT" choices:"json, yaml, table" default:"table" description:"Output format"`
+	Levels []Level  `long:"--level" separator:","`
+	Names  []string `placeholder:"NAME" choices:"a,b"`
+}
+
+func TestCommandLine_ParseChoices(t *testing.T) {
+	res, err := structcli.Create("cmd", "a test cmd", "1.0.0", new(ChoiceCommand)).Parse([]string{"--format=json", "--level=debug,warn", "b", "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := res.Command
+	if cmd.Format != "json" || !reflect.DeepEqual(cmd.Levels, []Level{"debug", "warn"}) || !reflect.DeepEqual(cmd.Names, []string{"b", "a"}) {
+		t.Fatalf("unexpected values: %+v", cmd)
+	}
+
+	tests := map[string][]string{
+		"invalid value \"xml\" for option `--format`: \"xml\" is not one of json, yaml, table":         {"--format", "xml"},
+		"invalid value \"info,trace\" for option `--level`: \"trace\" is not one of debug, info, warn": {"--level=info,trace"},
+		"invalid value \"c\" for positional `NAME`: \"c\" is not one of a, b":                          {"a", "c"},
+	}
+
+	for want, args := range tests {
+		_, err = structcli.Create("cmd", "a test cmd", "1.0.0", new(ChoiceCommand)).Parse(args)
+		if err == nil || err.Error() != want {
+			t.Errorf("expected %q for %q, got %v", want, args, err)
+		}
+	}
+
+	t.Setenv("COLUMNS", "120")
+
+	var b strings.Builder
+	cli := structcli.Create("cmd", "a test cmd", "1.0.0", new(ChoiceCommand))
+	cli.SetOutput(&b)
+
+	_, err = cli.Parse([]string{"--help"})
+	if !errors.Is(err, structcli.ErrHelp) || !strings.Contains(b.String(), "Output format [possible values: json, yaml, table] [default: table]") {
+		t.Errorf("choices are missing from help:\n%s", b.String())
+	}
+
+	b.Reset()
+	_, _ = cli.Parse([]string{"__complete", "--level", "d"})
+	if b.String() != "debug\t\n" {
+		t.Errorf("unexpected completions %q", b.String())
+	}
+
+	err = parseNew[struct {
+		Port int `long:"--port" choices:"80,http"`
+	}]()
+	if err == nil || !strings.Contains(err.Error(), "invalid choice \"http\"") {
+		t.Errorf("expected error for an invalid choice, got %v", err)
+	}
+}
+
+type ValidateCommand struct {
+	Port    int           `long:"--port" env:"PORT" min:"1" max:"65535"`
+	Timeout time.Duration `long:"--timeout" max:"1m"`
+	Name    string        `long:"--name" pattern:"[a-z]+" minlen:"2" maxlen:"8"`
+	Tags    []string      `long:"--tag" mincount:"1" maxcount:"2" minlen:"2"`
+	Files   []string      `placeholder:"FILE" maxcount:"1"`
+}
+
+func TestCommandLine_ParseValidate(t *testing.T) {
+	res, err := structcli.Create("cmd", "a test cmd", "1.0.0", new(ValidateCommand)).Parse([]string{"--port=80", "--name=web", "--tag=ab", "main.go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Command.Port != 80 || res.Command.Name != "web" {
+		t.Fatalf("unexpected values: %+v", res.Command)
+	}
+
+	_, err = structcli.Create("cmd", "a test cmd", "1.0.0", new(ValidateCommand)).Parse(nil)
+	if err == nil || err.Error() != "option `--tag` must have at least 1 value" {
+		t.Errorf("expected error for an absent option with a mincount, got %v", err)
+	}
+
+	err = parseNew[struct {
+		Big  int64  `long:"--big" max:"9007199254740992"`
+		Size uint64 `long:"--size" min:"18446744073709551615"`
+	}]("--big=9007199254740993", "--size=18446744073709551614")
+	if err == nil || err.Error() != "invalid value \"9007199254740993\" for option `--big`: must be at most 9007199254740992\ninvalid value \"18446744073709551614\" for option `--size`: must be at least 18446744073709551615" {
+		t.Errorf("expected large integers to be compared exactly, got %v", err)
+	}
+
+	for _, ratio := range []string{"--ratio=0.1", "--ratio=0.3"} {
+		err = parseNew[struct {
+			Ratio float32 `long:"--ratio" min:"0.1" max:"0.3"`
+		}](ratio)
+		if err != nil {
+			t.Errorf("float32 limits must be inclusive, got %v", err)
+		}
+	}
+
+	t.Setenv("PORT", "0")
+
+	_, err = structcli.Create("cmd", "a test cmd", "1.0.0", new(ValidateCommand)).Parse([]string{"--timeout=2m", "--name=Web", "--tag=a", "--tag=bc", "--tag=de", "a.go", "b.go"})
+
+	want := []string{
+		"invalid value \"0\" for option `--port` from environment variable PORT: must be at least 1",
+		"invalid value \"2m0s\" for option `--timeout`: must be at most 1m",
+		"invalid value \"Web\" for option `--name`: must match the pattern [a-z]+",
+		"invalid value \"a\" for option `--tag`: must be at least 2 characters long",
+		"invalid value \"a,bc,de\" for option `--tag`: must have at most 2 values",
+		"invalid value \"a.go,b.go\" for positional `FILE`: must have at most 1 value",
+	}
+
+	var validationErr *structcli.ValidationError
+	if !errors.As(err, &validationErr) || err.Error() != strings.Join(want, "\n") {
+		t.Fatalf("unexpected error:\n%v", err)
+	}
+
+	var invalidErr *structcli.InvalidValueError
+	if !errors.As(err, &invalidErr) || invalidErr.Origin.Source != structcli.SourceEnv {
+		t.Errorf("expected the violations to unwrap to InvalidValueError, got %v", err)
+	}
+}
+
+func parseNew[T any](args ...string) error {
+	_, err := structcli.Create("cmd", "a test cmd", "1.0.0", new(T)).Parse(args)
+	return err
+}
+
+func TestCommandLine_InvalidTags(t *testing.T) {
+	tests := []error{
+		parseNew[struct {
+			Port int `long:"--port" default:"http"`
+		}](),
+		parseNew[struct {
+			Port int `long:"--port" default:"80" required:"true"`
+		}](),
+		parseNew[struct {
+			Port int `long:"--port" required:"maybe"`
+		}](),
+		parseNew[struct {
+			Port int `long:"--port,negatable"`
+		}](),
+		parseNew[struct {
+			Port int `long:"--port" layout:"2006"`
+		}](),
+		parseNew[struct {
+			Port string `long:"--port" min:"1"`
+		}](),
+		parseNew[struct {
+			Port int `long:"--port" max:"high"`
+		}](),
+		parseNew[struct {
+			Port string `long:"--port" pattern:"[0-9"`
+		}](),
+		parseNew[struct {
+			Port int `long:"--port" mincount:"1"`
+		}](),
+		parseNew[struct {
+			Port int `long:"--port" min:"1.5"`
+		}](),
+	}
+
+	for i, err := range tests {
+		if err == nil || !strings.Contains(err.Error(), "Port") {
+			t.Errorf("expected error for case %d, got %v", i, err)
+		}
+	}
 }
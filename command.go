@@ -1,15 +1,27 @@
 package structcli
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
 	"strings"
+
+	"github.com/tsingmuhe/structcli/flag"
 )
 
 type commandSpec struct {
 	name        string
 	description string
 
+	parent *commandSpec
+	value  reflect.Value
+	field  reflect.Value
+	flags  *flag.FlagSet
+
+	versionFlag  *flag.Flag
+	versionShort bool
+	versionLong  bool
+
 	options       []*optionSpec
 	optionsByName map[string]*optionSpec
 
@@ -26,53 +38,87 @@ func (c *commandSpec) extractStruct(v reflect.Value, visited map[reflect.Type]bo
 		return fmt.Errorf("command type `%s` is referenced recursively", t.String())
 	}
 	visited[t] = true
+	defer delete(visited, t)
 
-	return scanStruct(t, v, func(field *structField, value reflect.Value) error {
-		if field.isCommand() {
-			cmd, err := newCommandSpec(field)
-			if err != nil {
-				return err
-			}
+	c.value = v
+	c.flags = flag.NewFlagSet(strings.Join(c.path(), " "), flag.ContinueOnError)
 
-			err = c.validateSubcommand(cmd)
-			if err != nil {
-				return err
-			}
+	return scanStruct(t, v, func(field *structField, value reflect.Value) error {
+		err := c.extractField(field, value, visited)
 
-			err = cmd.extractStruct(value.Elem(), visited)
-			if err != nil {
-				return err
-			}
+		// Errors of nested commands already name their own field.
+		var specErr *SpecError
+		if err != nil && !errors.As(err, &specErr) {
+			return &SpecError{Command: c.path(), Field: field.Name, Err: err}
+		}
+		return err
+	})
+}
 
-			c.addSubcommand(cmd)
-			return nil
+func (c *commandSpec) extractField(field *structField, value reflect.Value, visited map[reflect.Type]bool) error {
+	if field.isCommand() {
+		cmd, err := newCommandSpec(field)
+		if err != nil {
+			return err
 		}
 
-		if field.isOption() {
-			opt, err := newOptionSpec(field)
-			if err != nil {
-				return err
-			}
+		err = c.validateSubcommand(cmd)
+		if err != nil {
+			return err
+		}
 
-			err = c.validateOption(opt)
-			if err != nil {
-				return err
-			}
+		// Subcommands are bound to a fresh struct and only stored in the
+		// parent field once the user selects them.
+		ptr := value
+		if ptr.IsNil() {
+			ptr = reflect.New(value.Type().Elem())
+		}
+		cmd.parent = c
+		cmd.field = value
 
-			c.addOption(opt)
-			return nil
+		err = cmd.extractStruct(ptr.Elem(), visited)
+		if err != nil {
+			return err
 		}
 
-		pos, err := newPositionalSpec(field)
+		c.addSubcommand(cmd)
+		return nil
+	}
+
+	if field.isOption() {
+		opt, err := newOptionSpec(field, value)
 		if err != nil {
 			return err
 		}
 
-		if pos != nil {
-			c.positionals = append(c.positionals, pos)
+		err = c.validateOption(opt)
+		if err != nil {
+			return err
 		}
+
+		c.addOption(opt)
 		return nil
-	})
+	}
+
+	pos, err := newPositionalSpec(field, value)
+	if err != nil {
+		return err
+	}
+
+	err = c.validatePositional(pos)
+	if err != nil {
+		return err
+	}
+
+	c.positionals = append(c.positionals, pos)
+	return nil
+}
+
+func (c *commandSpec) path() []string {
+	if c.parent == nil {
+		return []string{c.name}
+	}
+	return append(c.parent.path(), c.name)
 }
 
 func (c *commandSpec) validateSubcommand(cmd *commandSpec) error {
@@ -110,6 +156,144 @@ func (c *commandSpec) addOption(opt *optionSpec) {
 	for _, name := range names {
 		c.optionsByName[name] = opt
 	}
+
+	opt.register(c.flags)
+	c.options = append(c.options, opt)
+}
+
+func (c *commandSpec) validatePositional(pos *positionalSpec) error {
+	if len(c.positionals) == 0 {
+		return nil
+	}
+
+	last := c.positionals[len(c.positionals)-1]
+	if last.isSlice {
+		return fmt.Errorf("positional `%s` must be the last one, it follows the variadic positional `%s`", pos.placeholder, last.placeholder)
+	}
+
+	if pos.required && !last.required {
+		return fmt.Errorf("required positional `%s` cannot follow the optional positional `%s`", pos.placeholder, last.placeholder)
+	}
+	return nil
+}
+
+// parse consumes args for this command and returns the deepest command that
+// was selected on the command line, which is also returned along with an
+// error, e.g. to render its help.
+func (c *commandSpec) parse(args []string) (*commandSpec, error) {
+	// A subcommand can only be selected by the first non-option argument, so
+	// stop there and decide before parsing any further.
+	c.flags.SetInterspersed(len(c.subcommands) == 0)
+
+	err := c.flags.Parse(args)
+	if err != nil {
+		return c, c.newParseError(err)
+	}
+
+	if c.versionRequested() {
+		return c, ErrVersion
+	}
+
+	args = c.flags.Args()
+	if len(c.subcommands) > 0 && len(args) > 0 && c.flags.ArgsLenAtDash() != 0 {
+		if sub, ok := c.subcommandsByName[args[0]]; ok {
+			sub.field.Set(sub.value.Addr())
+
+			// Positionals of a command are not expected once one of its
+			// subcommands has been selected.
+			return sub.parse(args[1:])
+		}
+
+		if len(c.positionals) == 0 {
+			return c, &UnknownCommandError{Command: c.path(), Name: args[0], Suggestions: c.suggestCommands(args[0])}
+		}
+
+		first := args[0]
+		c.flags.SetInterspersed(true)
+
+		err = c.flags.Parse(args[1:])
+		if err != nil {
+			return c, c.newParseError(err)
+		}
+
+		if c.versionRequested() {
+			return c, ErrVersion
+		}
+
+		args = append([]string{first}, c.flags.Args()...)
+	}
+
+	err = c.assignPositionals(args)
+	if err != nil {
+		return c, err
+	}
+	return c, nil
+}
+
+func (c *commandSpec) applyEnvPrefix(prefix string) {
+	for _, opt := range c.options {
+		if opt.envName != "" {
+			opt.envName = prefix + opt.envName
+		}
+	}
+
+	for _, sub := range c.subcommands {
+		sub.applyEnvPrefix(prefix)
+	}
+}
+
+// chain returns the commands from the root to c.
+func (c *commandSpec) chain() []*commandSpec {
+	if c.parent == nil {
+		return []*commandSpec{c}
+	}
+	return append(c.parent.chain(), c)
+}
+
+// resolveOptions completes the options missing from the command line and
+// validates them, once the whole command line has been parsed.
+func (c *commandSpec) resolveOptions(config *Config) error {
+	for _, opt := range c.options {
+		err := opt.resolve(c.path(), config)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *commandSpec) assignPositionals(positionals []string) error {
+	for _, pos := range c.positionals {
+		if len(positionals) == 0 {
+			if pos.required {
+				return &MissingRequiredError{Command: c.path(), Name: pos.placeholder}
+			}
+
+			origin, err := pos.applyDefault()
+			if err != nil {
+				return err
+			}
+
+			pos.origin = origin
+			continue
+		}
+
+		n := 1
+		if pos.isSlice {
+			n = len(positionals)
+		}
+
+		err := pos.set(c.path(), positionals[:n])
+		if err != nil {
+			return err
+		}
+		positionals = positionals[n:]
+	}
+
+	if len(positionals) > 0 {
+		return &UnexpectedArgumentError{Command: c.path(), Arg: positionals[0]}
+	}
+	return nil
 }
 
 func newCommandSpec(sf *structField) (*commandSpec, error) {
@@ -0,0 +1,180 @@
+package structcli
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// ErrCompletion is returned by Parse and Execute after printing the
+// completions requested by a completion script.
+var ErrCompletion = errors.New("completion requested")
+
+// completeCommand is the hidden command the completion scripts call back
+// with the words typed after the program name, the last one being the word
+// to complete. It prints a candidate value per line, followed by a tab and
+// its description.
+const completeCommand = "__complete"
+
+// A Completion is a candidate value offered by shell completion.
+type Completion struct {
+	Value       string
+	Description string
+}
+
+// Completer is implemented by option and positional field types, or their
+// element type for slices and maps, that complete their own values. prefix
+// is the part of the value typed so far.
+type Completer interface {
+	Complete(prefix string) []Completion
+}
+
+// CompletionFunc completes the values of an option or a positional, like
+// Completer.
+type CompletionFunc func(prefix string) []Completion
+
+// RegisterCompletion registers fn to complete the values of the options
+// named name, e.g. "--cluster", and of the positionals whose placeholder is
+// name, in every command. It takes precedence over a Completer.
+func (c *CommandLine[T]) RegisterCompletion(name string, fn CompletionFunc) {
+	if c.completions == nil {
+		c.completions = make(map[string]CompletionFunc)
+	}
+	c.completions[name] = fn
+}
+
+func (c *CommandLine[T]) isCompletion(args []string) bool {
+	return len(args) > 0 && args[0] == completeCommand && c.commandSpec.subcommandsByName[completeCommand] == nil
+}
+
+// complete prints the completions of the last of words, the arguments typed
+// after the program name. Option names and subcommands are completed by the
+// scripts themselves, only values are completed here.
+func (c *CommandLine[T]) complete(w io.Writer, words []string) error {
+	words = joinAssignments(words)
+	cur := ""
+	if len(words) > 0 {
+		cur, words = words[len(words)-1], words[:len(words)-1]
+	}
+
+	cmd := c.commandSpec
+	positionals := 0
+	dashdash := false
+	var pending *optionSpec
+
+	for _, word := range words {
+		switch {
+		case pending != nil:
+			pending = nil
+		case dashdash || word == "-" || !strings.HasPrefix(word, "-"):
+			if sub := cmd.subcommandsByName[word]; sub != nil && !dashdash && positionals == 0 {
+				cmd = sub
+				continue
+			}
+			positionals++
+		case word == "--":
+			dashdash = true
+		case strings.Contains(word, "="):
+		case strings.HasPrefix(word, "--"):
+			pending = cmd.valueOption(word)
+		default:
+			pending = cmd.valueOption("-" + word[len(word)-1:])
+		}
+	}
+
+	var names, choices []string
+	var completer Completer
+	prefix := cur
+
+	switch {
+	case pending != nil:
+		names, choices, completer = pending.getNames(), pending.choices, pending.completer
+	case !dashdash && strings.HasPrefix(cur, "-"):
+		var name string
+		name, prefix, _ = strings.Cut(cur, "=")
+		if opt := cmd.valueOption(name); opt != nil && strings.Contains(cur, "=") {
+			names, choices, completer = opt.getNames(), opt.choices, opt.completer
+		}
+	default:
+		if pos := cmd.positionalAt(positionals); pos != nil {
+			names, choices, completer = []string{pos.placeholder}, pos.choices, pos.completer
+		}
+	}
+
+	var completions []Completion
+	for _, name := range names {
+		if fn := c.completions[name]; fn != nil {
+			completions = fn(prefix)
+			break
+		}
+	}
+	if completions == nil && completer != nil {
+		completions = completer.Complete(prefix)
+	}
+	if completions == nil {
+		completions = choiceCompletions(choices)
+	}
+
+	var b strings.Builder
+	for _, completion := range completions {
+		if strings.HasPrefix(completion.Value, prefix) {
+			b.WriteString(completion.Value + "\t" + completion.Description + "\n")
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// joinAssignments joins the words "--name", "=" and "value" that bash splits
+// an option assignment into.
+func joinAssignments(words []string) []string {
+	var joined []string
+	for i := 0; i < len(words); i++ {
+		word := words[i]
+		if word == "=" && len(joined) > 0 && strings.HasPrefix(joined[len(joined)-1], "-") {
+			joined[len(joined)-1] += "="
+			if i+1 < len(words) {
+				i++
+				joined[len(joined)-1] += words[i]
+			}
+			continue
+		}
+		joined = append(joined, word)
+	}
+	return joined
+}
+
+// valueOption returns the option of c named name if it takes a value.
+func (c *commandSpec) valueOption(name string) *optionSpec {
+	opt := c.optionsByName[name]
+	if opt == nil || opt.isBool {
+		return nil
+	}
+	return opt
+}
+
+// positionalAt returns the positional receiving the argument at index i.
+func (c *commandSpec) positionalAt(i int) *positionalSpec {
+	if i < len(c.positionals) {
+		return c.positionals[i]
+	}
+
+	if n := len(c.positionals); n > 0 && c.positionals[n-1].isSlice {
+		return c.positionals[n-1]
+	}
+	return nil
+}
+
+// getCompleter returns the Completer implemented by the type of the field or
+// by its element type, if any.
+func getCompleter(sf *structField) Completer {
+	t, _ := sf.indirectType()
+	for _, t := range []reflect.Type{t, sf.elemType()} {
+		if completer, ok := reflect.New(t).Interface().(Completer); ok {
+			return completer
+		}
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package structcli
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteCompletion writes to w a completion script for the given shell, which
+// must be "bash", "zsh" or "fish". The script is meant to be sourced by the
+// shell, e.g. with `source <(cmd completion bash)` when the program exposes
+// it that way.
+func (c *CommandLine[T]) WriteCompletion(w io.Writer, shell string) error {
+	err := c.parseCommandSpec()
+	if err != nil {
+		return err
+	}
+
+	var script string
+	switch shell {
+	case "bash":
+		script = c.commandSpec.bashCompletion()
+	case "zsh":
+		script = c.commandSpec.zshCompletion()
+	case "fish":
+		script = c.commandSpec.fishCompletion()
+	default:
+		return fmt.Errorf("unsupported shell %q", shell)
+	}
+
+	_, err = io.WriteString(w, script)
+	return err
+}
+
+// commands returns c and all its descendants, parents first.
+func (c *commandSpec) commands() []*commandSpec {
+	commands := []*commandSpec{c}
+	for _, sub := range c.subcommands {
+		commands = append(commands, sub.commands()...)
+	}
+	return commands
+}
+
+// completionOptions returns every name accepted as an option by c, including
+// negations and built-in options, and the subset of those taking a value.
+func (c *commandSpec) completionOptions() (names, values []string) {
+	for _, opt := range c.options {
+		names = append(names, opt.getNames()...)
+		if !opt.isBool {
+			if opt.shortName != "" {
+				values = append(values, opt.shortName)
+			}
+			if opt.longName != "" {
+				values = append(values, opt.longName)
+			}
+		}
+	}
+
+	names = append(names, c.builtinNames()...)
+	return names, values
+}
+
+// valuePlaceholder returns the placeholder shown for the value of o in
+// completions.
+func (o *optionSpec) valuePlaceholder() string {
+	if o.placeholder == "" {
+		return "VALUE"
+	}
+	return o.placeholder
+}
+
+// shellIdentifier turns name into a valid shell function name.
+func shellIdentifier(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// shellQuote quotes s for POSIX-like shells.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,85 @@
+package structcli
+
+import (
+	"fmt"
+	"strings"
+)
+
+// bashCompletionBody walks the words before the cursor to find the selected
+// command, skipping option values, then completes option names, or
+// subcommands along with the values completed by the program itself.
+// Nothing but files is completed after "--".
+//
+// It is formatted with the function name and the quoted program name.
+const bashCompletionBody = `%[1]s() {
+	local cur=${COMP_WORDS[COMP_CWORD]} path=%[2]s
+	local commands options values word i
+	%[1]s_spec "$path"
+
+	for ((i = 1; i < COMP_CWORD; i++)); do
+		word=${COMP_WORDS[i]}
+		case $word in
+		--)
+			COMPREPLY=()
+			return
+			;;
+		--*=*) ;;
+		-*)
+			[[ $word == --* ]] || word=-${word: -1}
+			if [[ " $values " == *" $word "* ]]; then
+				# bash splits --name=value at the "=" by default.
+				[[ ${COMP_WORDS[i+1]} == = ]] && ((i++))
+				((i++))
+			fi
+			;;
+		*)
+			if [[ " $commands " == *" $word "* ]]; then
+				path="$path $word"
+				%[1]s_spec "$path"
+			fi
+			;;
+		esac
+	done
+
+	# The loop went past the cursor when completing the value of an option.
+	if ((i > COMP_CWORD)); then
+		commands=
+	elif [[ $cur == -* ]]; then
+		COMPREPLY=($(compgen -W "$options" -- "$cur"))
+		return
+	fi
+
+	COMPREPLY=($(compgen -W "$commands" -- "$cur"))
+
+	local IFS=$'\n'
+	COMPREPLY+=($("${COMP_WORDS[0]}" __complete "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null | cut -f1))
+}
+
+complete -o default -F %[1]s %[2]s
+`
+
+func (c *commandSpec) bashCompletion() string {
+	fn := "_" + shellIdentifier(c.name)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# bash completion for %s\n\n", c.name)
+
+	fmt.Fprintf(&b, "%s_spec() {\n\tcase $1 in\n", fn)
+	for _, cmd := range c.commands() {
+		var commands []string
+		for _, sub := range cmd.subcommands {
+			commands = append(commands, sub.name)
+		}
+		options, values := cmd.completionOptions()
+
+		fmt.Fprintf(&b, "\t%s)\n", shellQuote(strings.Join(cmd.path(), " ")))
+		fmt.Fprintf(&b, "\t\tcommands=%s\n", shellQuote(strings.Join(commands, " ")))
+		fmt.Fprintf(&b, "\t\toptions=%s\n", shellQuote(strings.Join(options, " ")))
+		fmt.Fprintf(&b, "\t\tvalues=%s\n", shellQuote(strings.Join(values, " ")))
+		b.WriteString("\t\t;;\n")
+	}
+	b.WriteString("\tesac\n}\n\n")
+
+	fmt.Fprintf(&b, bashCompletionBody, fn, shellQuote(c.name))
+	return b.String()
+}
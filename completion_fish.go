@@ -0,0 +1,170 @@
+package structcli
+
+import (
+	"fmt"
+	"strings"
+)
+
+// fishCompletionBody defines the helpers the completions are conditioned on:
+// the _using helper tells whether the given command is the selected one,
+// found like in the bash script, and the _seen helper whether one of the
+// given options has already been set. No command is selected after "--".
+// The _complete helper calls the program back to complete values.
+//
+// It is formatted with the helper prefix.
+const fishCompletionBody = `function %[1]s_path
+	set -l words (commandline -opc)
+	set -l path $words[1]
+	set -l skip 0
+	for word in $words[2..-1]
+		if test $skip -eq 1
+			set skip 0
+			continue
+		end
+
+		switch $word
+			case --
+				return
+			case '--*=*'
+			case '--*'
+				contains -- $word (%[1]s_values $path); and set skip 1
+			case '-*'
+				contains -- -(string sub -s -1 -- $word) (%[1]s_values $path); and set skip 1
+			case '*'
+				contains -- $word (%[1]s_commands $path); and set path "$path $word"
+		end
+	end
+	echo $path
+end
+
+function %[1]s_using
+	set -l path (%[1]s_path)
+	test "$path" = $argv[1]
+end
+
+function %[1]s_complete
+	set -l words (commandline -opc) (commandline -ct)
+	$words[1] __complete $words[2..-1] 2>/dev/null
+end
+
+function %[1]s_seen
+	for word in (commandline -opc)[2..-1]
+		switch $word
+			case --
+				return 1
+			case '--*'
+				contains -- (string replace -r '=.*' '' -- $word) $argv; and return 0
+			case '-*'
+				for short in (string sub -s 2 -- $word | string split '')
+					contains -- -$short $argv; and return 0
+				end
+		end
+	end
+	return 1
+end
+`
+
+func (c *commandSpec) fishCompletion() string {
+	prefix := "__" + shellIdentifier(c.name)
+	name := fishQuote(c.name)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# fish completion for %s\n\n", c.name)
+
+	for _, list := range []string{"commands", "values"} {
+		fmt.Fprintf(&b, "function %s_%s\n\tswitch $argv[1]\n", prefix, list)
+		for _, cmd := range c.commands() {
+			var names []string
+			if list == "commands" {
+				for _, sub := range cmd.subcommands {
+					names = append(names, fishQuote(sub.name))
+				}
+			} else {
+				_, names = cmd.completionOptions()
+			}
+
+			fmt.Fprintf(&b, "\t\tcase %s\n", fishQuote(strings.Join(cmd.path(), " ")))
+			if len(names) > 0 {
+				fmt.Fprintf(&b, "\t\t\tstring join \\n -- %s\n", strings.Join(names, " "))
+			}
+		}
+		b.WriteString("\tend\nend\n\n")
+	}
+
+	fmt.Fprintf(&b, fishCompletionBody, prefix)
+
+	for _, cmd := range c.commands() {
+		using := prefix + "_using " + fishQuote(strings.Join(cmd.path(), " "))
+		b.WriteString("\n")
+
+		// Files are only completed by commands taking positionals.
+		noFiles := ""
+		if len(cmd.positionals) == 0 {
+			noFiles = " -f"
+		}
+		fmt.Fprintf(&b, "complete -c %s%s -n %s\n", name, noFiles, fishQuote(using))
+		if len(cmd.positionals) > 0 {
+			fmt.Fprintf(&b, "complete -c %s -n %s -a '(%s_complete)'\n", name, fishQuote(using), prefix)
+		}
+
+		for _, sub := range cmd.subcommands {
+			fmt.Fprintf(&b, "complete -c %s -f -n %s -a %s -d %s\n", name, fishQuote(using), fishQuote(sub.name), fishQuote(sub.description))
+		}
+
+		for _, opt := range cmd.options {
+			condition := using
+			if !opt.repeatable {
+				condition += "; and not " + prefix + "_seen " + strings.Join(opt.getNames(), " ")
+			}
+
+			description := opt.description
+			flags := fishFlags(opt.shortName, opt.longName)
+			if !opt.isBool {
+				description = strings.TrimSpace(description + " <" + opt.valuePlaceholder() + ">")
+				flags += " -r -a '(" + prefix + "_complete)'"
+			}
+			fmt.Fprintf(&b, "complete -c %s -n %s%s -d %s\n", name, fishQuote(condition), flags, fishQuote(description))
+
+			if opt.negatable {
+				fmt.Fprintf(&b, "complete -c %s -n %s -l %s -d %s\n", name, fishQuote(condition), "no-"+strings.TrimPrefix(opt.longName, "--"), fishQuote(opt.description))
+			}
+		}
+
+		var help, version []string
+		for _, n := range cmd.builtinNames() {
+			if n == "-V" || n == "--version" {
+				version = append(version, n)
+			} else {
+				help = append(help, n)
+			}
+		}
+		if len(help) > 0 {
+			fmt.Fprintf(&b, "complete -c %s -n %s%s -d 'Print help'\n", name, fishQuote(using), fishFlags(help...))
+		}
+		if len(version) > 0 {
+			fmt.Fprintf(&b, "complete -c %s -n %s%s -d 'Print version'\n", name, fishQuote(using), fishFlags(version...))
+		}
+	}
+	return b.String()
+}
+
+// fishFlags returns the -s and -l arguments of complete for the given
+// option names.
+func fishFlags(names ...string) string {
+	var flags string
+	for _, n := range names {
+		switch {
+		case strings.HasPrefix(n, "--"):
+			flags += " -l " + n[2:]
+		case n != "":
+			flags += " -s " + n[1:]
+		}
+	}
+	return flags
+}
+
+// fishQuote quotes s for fish, where only backslashes and single quotes are
+// special within single quotes.
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
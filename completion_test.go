@@ -0,0 +1,177 @@
+package structcli_test
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/tsingmuhe/structcli"
+)
+
+// complete sources script in shell and runs the given completion commands,
+// returning their output.
+func complete(t *testing.T, shell, script, commands string) string {
+	t.Helper()
+
+	path, err := exec.LookPath(shell)
+	if err != nil {
+		t.Skipf("%s is not installed", shell)
+	}
+
+	out, err := exec.Command(path, "-c", script+"\n"+commands).CombinedOutput()
+	if err != nil {
+		t.Fatalf("%v: %s", err, out)
+	}
+	return string(out)
+}
+
+func TestCommandLine_WriteCompletion_Bash(t *testing.T) {
+	var b strings.Builder
+	err := structcli.Create("cmd", "a test cmd", "1.0.0", new(MainCommand)).WriteCompletion(&b, "bash")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]string{
+		"cmd ":                      "sub other",
+		"cmd --he":                  "--hello1 --hello2 --hello3 --hello4 --help",
+		"cmd -a sub sub --no":       "--no-hello3 --no-hello4",
+		"cmd --hello1 sub s":        "sub",
+		"cmd --hello1 = x o":        "other",
+		"cmd sub -ba other -":       "-a --hello1 -b --hello2 -c --hello3 --no-hello3 -d --hello4 --no-hello4 -h --help",
+		"cmd -- --h":                "",
+		"cmd --version sub --hello": "--hello1 --hello2 --hello3 --hello4",
+	}
+
+	for line, want := range tests {
+		words := strings.Split(line, " ")
+		for i, word := range words {
+			words[i] = strconv.Quote(word)
+		}
+
+		commands := fmt.Sprintf("COMP_WORDS=(%s); COMP_CWORD=%d; _cmd; echo \"${COMPREPLY[*]}\"", strings.Join(words, " "), len(words)-1)
+		got := strings.TrimSpace(complete(t, "bash", b.String(), commands))
+		if got != want {
+			t.Errorf("unexpected completion for %q: %q, want %q", line, got, want)
+		}
+	}
+
+	// The program is called back to complete values.
+	program := "cmd() { [[ $1 == __complete && $2 == --hello1 ]] && printf 'x1\\tdesc\\n'; }\n"
+	got := strings.TrimSpace(complete(t, "bash", b.String(), program+`COMP_WORDS=(cmd --hello1 ""); COMP_CWORD=2; _cmd; echo "${COMPREPLY[*]}"`))
+	if got != "x1" {
+		t.Errorf("unexpected value completion %q", got)
+	}
+
+	err = structcli.Create("cmd", "a test cmd", "1.0.0", new(MainCommand)).WriteCompletion(&b, "tcsh")
+	if err == nil {
+		t.Error("expected error for an unsupported shell")
+	}
+}
+
+func TestCommandLine_WriteCompletion_Zsh(t *testing.T) {
+	var b strings.Builder
+	err := structcli.Create("cmd", "a test cmd", "1.0.0", new(HelpCommand)).WriteCompletion(&b, "zsh")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, line := range []string{
+		"#compdef cmd\n",
+		"\t\t'(-v --verbose --no-verbose)--no-verbose[Print more output while running]' \\\n",
+		"\t\t'(-c --config)-c+[Config file]:FILE:__cmd_complete' \\\n",
+		"\t\t'*--tag=[Tags]:TAG:__cmd_complete' \\\n",
+		"\t\t*) __cmd_complete ;;\n",
+		"\t\tlocal -a commands=('sub:Sub command')\n",
+		"\t\t'sub') _cmd_sub ;;\n",
+		"\t\t'(-H --help)--help[Custom help]' \\\n\t\t'(- *)-h[Print help]'\n",
+	} {
+		if !strings.Contains(b.String(), line) {
+			t.Errorf("script does not contain %q:\n%s", line, b.String())
+		}
+	}
+
+	if _, err := exec.LookPath("zsh"); err == nil {
+		complete(t, "zsh", "setopt no_exec\n"+b.String(), "")
+	}
+}
+
+func TestCommandLine_WriteCompletion_Fish(t *testing.T) {
+	var b strings.Builder
+	err := structcli.Create("cmd", "a test cmd", "1.0.0", new(HelpCommand)).WriteCompletion(&b, "fish")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, line := range []string{
+		"\t\tcase 'cmd'\n\t\t\tstring join \\n -- -c --config --name --tag -l --label\n",
+		"complete -c 'cmd' -f -n '__cmd_using \\'cmd\\'' -a 'sub' -d 'Sub command'\n",
+		"complete -c 'cmd' -n '__cmd_using \\'cmd\\'; and not __cmd_seen -c --config' -s c -l config -r -a '(__cmd_complete)' -d 'Config file <FILE>'\n",
+		"complete -c 'cmd' -n '__cmd_using \\'cmd\\'' -l tag -r -a '(__cmd_complete)' -d 'Tags <TAG>'\n",
+		"complete -c 'cmd' -n '__cmd_using \\'cmd\\'' -a '(__cmd_complete)'\n",
+		"complete -c 'cmd' -f -n '__cmd_using \\'cmd sub\\''\n",
+		"complete -c 'cmd' -n '__cmd_using \\'cmd sub\\'' -s h -d 'Print help'\n",
+	} {
+		if !strings.Contains(b.String(), line) {
+			t.Errorf("script does not contain %q:\n%s", line, b.String())
+		}
+	}
+
+	if _, err := exec.LookPath("fish"); err == nil {
+		complete(t, "fish", b.String(), "")
+	}
+}
+
+type Cluster string
+
+func (c *Cluster) Complete(prefix string) []structcli.Completion {
+	return []structcli.Completion{{Value: "prod", Description: "Production"}, {Value: "staging", Description: "Staging"}}
+}
+
+type CompleteCommand struct {
+	Cluster Cluster `short:"-c" long:"--cluster"`
+	Verbose bool    `short:"-v" long:"--verbose"`
+
+	Deploy *CompleteDeployCommand `command:"deploy"`
+}
+
+type CompleteDeployCommand struct {
+	Branch string   `placeholder:"BRANCH"`
+	Files  []string `placeholder:"FILE"`
+}
+
+func TestCommandLine_Complete(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"--cluster", ""}, "prod\tProduction\nstaging\tStaging\n"},
+		{[]string{"-vc", "st"}, "staging\tStaging\n"},
+		{[]string{"--cluster=p"}, "prod\tProduction\n"},
+		{[]string{"--cluster", "=", "p"}, "prod\tProduction\n"},
+		{[]string{"deploy", "m"}, "main\tDefault branch\n"},
+		{[]string{"deploy", "--", ""}, "main\tDefault branch\nfeature\t\n"},
+		{[]string{"deploy", "main", ""}, ""},
+	}
+
+	for _, test := range tests {
+		var b strings.Builder
+		cli := structcli.Create("cmd", "a test cmd", "1.0.0", new(CompleteCommand))
+		cli.SetOutput(&b)
+		cli.RegisterCompletion("BRANCH", func(prefix string) []structcli.Completion {
+			return []structcli.Completion{{Value: "main", Description: "Default branch"}, {Value: "feature"}}
+		})
+
+		_, err := cli.Parse(append([]string{"__complete"}, test.args...))
+		if !errors.Is(err, structcli.ErrCompletion) {
+			t.Errorf("expected ErrCompletion for %q, got %v", test.args, err)
+		}
+
+		if got := b.String(); got != test.want {
+			t.Errorf("unexpected completions for %q: %q, want %q", test.args, got, test.want)
+		}
+	}
+}
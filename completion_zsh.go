@@ -0,0 +1,180 @@
+package structcli
+
+import (
+	"fmt"
+	"strings"
+)
+
+// zshCompleteBody completes values by calling the program back with the
+// words left of the cursor, falling back to files. It is formatted with the
+// function name.
+const zshCompleteBody = `%[1]s() {
+	local -a args=("${(@Q)${(z)LBUFFER}}") lines completions
+	local line value
+	[[ $LBUFFER == *' ' ]] && args+=('')
+
+	lines=("${(@f)$(${args[1]} __complete "${(@)args[2,-1]}" 2>/dev/null)}")
+	for line in $lines; do
+		value=${line%%%%$'\t'*}
+		completions+=("${value//:/\\:}:${line#*$'\t'}")
+	done
+
+	if (($#completions)); then
+		_describe -t values value completions
+	else
+		_files
+	fi
+}
+`
+
+// zshCompletion returns a script defining one function per command, each
+// calling _arguments with the options and positionals of the command and
+// dispatching to the function of the selected subcommand.
+func (c *commandSpec) zshCompletion() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "#compdef %s\n\n", c.name)
+	fmt.Fprintf(&b, zshCompleteBody, c.zshCompleteFunction())
+
+	for _, cmd := range c.commands() {
+		b.WriteString("\n")
+		cmd.writeZshFunction(&b)
+	}
+
+	fn := c.zshFunction()
+	fmt.Fprintf(&b, "\nif [ \"$funcstack[1]\" = %s ]; then\n\t%s \"$@\"\nelse\n\tcompdef %s %s\nfi\n", fn, fn, fn, shellQuote(c.name))
+	return b.String()
+}
+
+func (c *commandSpec) zshFunction() string {
+	return "_" + shellIdentifier(strings.Join(c.path(), "_"))
+}
+
+// zshCompleteFunction returns the name of the function completing values,
+// shared by all commands.
+func (c *commandSpec) zshCompleteFunction() string {
+	root := c
+	for root.parent != nil {
+		root = root.parent
+	}
+	return "__" + shellIdentifier(root.name) + "_complete"
+}
+
+func (c *commandSpec) writeZshFunction(b *strings.Builder) {
+	complete := c.zshCompleteFunction()
+
+	var specs []string
+	for _, opt := range c.options {
+		specs = append(specs, opt.zshSpecs(complete)...)
+	}
+
+	for _, name := range c.builtinNames() {
+		description := "Print help"
+		if name == "-V" || name == "--version" {
+			description = "Print version"
+		}
+		specs = append(specs, "(- *)"+name+"["+description+"]")
+	}
+
+	if len(c.subcommands) > 0 {
+		specs = append(specs, ": :->command", "*:: :->args")
+	} else {
+		for _, pos := range c.positionals {
+			specs = append(specs, pos.zshSpec(complete))
+		}
+	}
+
+	fmt.Fprintf(b, "%s() {\n", c.zshFunction())
+	b.WriteString("\tlocal curcontext=$curcontext state line\n")
+	b.WriteString("\ttypeset -A opt_args\n\n")
+	b.WriteString("\t_arguments -s -S -C")
+	for _, spec := range specs {
+		b.WriteString(" \\\n\t\t" + shellQuote(spec))
+	}
+	b.WriteString("\n")
+
+	if len(c.subcommands) > 0 {
+		var commands []string
+		for _, sub := range c.subcommands {
+			commands = append(commands, shellQuote(zshEscape(sub.name, ":")+":"+sub.description))
+		}
+
+		b.WriteString("\n\tcase $state in\n\tcommand)\n")
+		fmt.Fprintf(b, "\t\tlocal -a commands=(%s)\n", strings.Join(commands, " "))
+		b.WriteString("\t\t_describe -t commands command commands\n")
+		if len(c.positionals) > 0 {
+			b.WriteString("\t\t" + complete + "\n")
+		}
+		b.WriteString("\t\t;;\n\targs)\n\t\tcase $line[1] in\n")
+		for _, sub := range c.subcommands {
+			fmt.Fprintf(b, "\t\t%s) %s ;;\n", shellQuote(sub.name), sub.zshFunction())
+		}
+		if len(c.positionals) > 0 {
+			b.WriteString("\t\t*) " + complete + " ;;\n")
+		}
+		b.WriteString("\t\tesac\n\t\t;;\n\tesac\n")
+	}
+	b.WriteString("}\n")
+}
+
+// zshSpecs returns the _arguments specs of the option names, completing
+// values with the complete function. The names of an option exclude each
+// other, unless it is repeatable.
+func (o *optionSpec) zshSpecs(complete string) []string {
+	prefix := "*"
+	if !o.repeatable {
+		prefix = "(" + strings.Join(o.getNames(), " ") + ")"
+	}
+
+	description := "[" + zshEscape(o.description, "[]:") + "]"
+	value := ""
+	if !o.isBool {
+		value = ":" + zshEscape(o.valuePlaceholder(), ":") + ":" + complete
+	}
+
+	var specs []string
+	if o.shortName != "" {
+		spec := prefix + o.shortName
+		if !o.isBool {
+			spec += "+"
+		}
+		specs = append(specs, spec+description+value)
+	}
+
+	if o.longName != "" {
+		spec := prefix + o.longName
+		if !o.isBool {
+			spec += "="
+		}
+		specs = append(specs, spec+description+value)
+	}
+
+	if o.negatable {
+		specs = append(specs, prefix+"--no-"+strings.TrimPrefix(o.longName, "--")+description)
+	}
+	return specs
+}
+
+func (p *positionalSpec) zshSpec(complete string) string {
+	message := zshEscape(p.placeholder, ":")
+	switch {
+	case p.isSlice:
+		return "*:" + message + ":" + complete
+	case p.required:
+		return ":" + message + ":" + complete
+	default:
+		return "::" + message + ":" + complete
+	}
+}
+
+// zshEscape escapes with a backslash the characters of s that are special in
+// an _arguments spec, along with backslashes themselves.
+func zshEscape(s, special string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r == '\\' || strings.ContainsRune(special, r) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
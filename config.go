@@ -0,0 +1,204 @@
+package structcli
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Config holds the values decoded from a configuration file. Values are keyed
+// by option, sections by subcommand name or by map option.
+type Config struct {
+	Values   map[string]*ConfigValue
+	Sections map[string]*Config
+	Line     int
+
+	path string
+}
+
+// ConfigValue is a value read from a configuration file, holding several
+// values for arrays.
+type ConfigValue struct {
+	Values []string
+	Line   int
+}
+
+// ConfigFormat decodes configuration files. Decode may return a
+// *ConfigError to report the line an error is about, its File and Command are
+// then filled in.
+type ConfigFormat interface {
+	Decode(data []byte) (*Config, error)
+}
+
+var (
+	// JSONConfig decodes JSON objects, where nested objects are sections.
+	JSONConfig ConfigFormat = jsonFormat{}
+
+	// INIConfig decodes `key = value` lines grouped by `[section]` headers,
+	// with TOML-like quoted strings and arrays.
+	INIConfig ConfigFormat = iniFormat{}
+)
+
+func newConfig(line int) *Config {
+	return &Config{
+		Values:   make(map[string]*ConfigValue),
+		Sections: make(map[string]*Config),
+		Line:     line,
+	}
+}
+
+func (c *Config) setValue(key string, values []string, line int) error {
+	if _, ok := c.Values[key]; ok {
+		return &ConfigError{Line: line, Err: fmt.Errorf("duplicated key %q", key)}
+	}
+
+	c.Values[key] = &ConfigValue{Values: values, Line: line}
+	return nil
+}
+
+func (c *Config) setPath(path string) {
+	c.path = path
+	for _, section := range c.Sections {
+		section.setPath(path)
+	}
+}
+
+func (c *Config) section(name string) *Config {
+	if c == nil {
+		return nil
+	}
+	return c.Sections[name]
+}
+
+type configEntry struct {
+	value string
+	line  int
+}
+
+// lookup returns the values of the given key. The section of a map option
+// is also looked up and turned into key=value pairs.
+func (c *Config) lookup(key string, isMap bool) []configEntry {
+	if c == nil {
+		return nil
+	}
+
+	var entries []configEntry
+	if value, ok := c.Values[key]; ok {
+		for _, v := range value.Values {
+			entries = append(entries, configEntry{v, value.Line})
+		}
+		return entries
+	}
+
+	section := c.Sections[key]
+	if !isMap || section == nil {
+		return nil
+	}
+
+	keys := make([]string, 0, len(section.Values))
+	for k := range section.Values {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	for _, k := range keys {
+		value := section.Values[k]
+		for _, v := range value.Values {
+			entries = append(entries, configEntry{k + "=" + v, value.Line})
+		}
+	}
+	return entries
+}
+
+func (c *commandSpec) optionByConfigKey(key string) *optionSpec {
+	for _, opt := range c.options {
+		if opt.configKey == key {
+			return opt
+		}
+	}
+	return nil
+}
+
+// loadConfig reads the configuration file named by the given root option.
+// A missing file is ignored when its path comes from the option default.
+func (c *commandSpec) loadConfig(option string, format ConfigFormat) (*Config, error) {
+	opt := c.optionsByName[option]
+	if opt == nil {
+		return nil, &SpecError{Command: c.path(), Err: fmt.Errorf("config option `%s` is not defined", option)}
+	}
+
+	err := opt.resolve(c.path(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	path := opt.value.String()
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) && opt.origin.Source == SourceDefault {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, &ConfigError{Command: c.path(), File: path, Err: err}
+	}
+
+	if format == nil {
+		format = INIConfig
+		if strings.EqualFold(filepath.Ext(path), ".json") {
+			format = JSONConfig
+		}
+	}
+
+	config, err := format.Decode(data)
+	if err != nil {
+		var configErr *ConfigError
+		if !errors.As(err, &configErr) {
+			configErr = &ConfigError{Err: err}
+		}
+
+		configErr.Command, configErr.File = c.path(), path
+		return nil, configErr
+	}
+
+	config.setPath(path)
+
+	err = c.validateConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// validateConfig checks that every key and section of the configuration
+// matches an option or a subcommand.
+func (c *commandSpec) validateConfig(config *Config) error {
+	for key, value := range config.Values {
+		if c.optionByConfigKey(key) == nil {
+			return &ConfigError{Command: c.path(), File: config.path, Line: value.Line, Err: fmt.Errorf("unknown key %q", key)}
+		}
+	}
+
+	for key, section := range config.Sections {
+		if sub, ok := c.subcommandsByName[key]; ok {
+			err := sub.validateConfig(section)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		opt := c.optionByConfigKey(key)
+		if opt == nil || !opt.isMap || len(section.Sections) > 0 {
+			return &ConfigError{Command: c.path(), File: config.path, Line: section.Line, Err: fmt.Errorf("unknown section %q", key)}
+		}
+	}
+	return nil
+}
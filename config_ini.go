@@ -0,0 +1,141 @@
+package structcli
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type iniFormat struct{}
+
+func (iniFormat) Decode(data []byte) (*Config, error) {
+	root := newConfig(0)
+	current := root
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || text[0] == '#' || text[0] == ';' {
+			continue
+		}
+
+		if text[0] == '[' {
+			name, ok := strings.CutSuffix(text[1:], "]")
+			if !ok || strings.TrimSpace(name) == "" {
+				return nil, &ConfigError{Line: line, Err: fmt.Errorf("invalid section header %q", text)}
+			}
+
+			// Dotted names select nested sections, e.g. [sub.deploy].
+			current = root
+			for _, part := range strings.Split(name, ".") {
+				part = strings.TrimSpace(part)
+
+				section, ok := current.Sections[part]
+				if !ok {
+					section = newConfig(line)
+					current.Sections[part] = section
+				}
+				current = section
+			}
+			continue
+		}
+
+		key, value, ok := strings.Cut(text, "=")
+		if !ok {
+			return nil, &ConfigError{Line: line, Err: fmt.Errorf("expected key = value, got %q", text)}
+		}
+
+		values, err := parseINIValue(strings.TrimSpace(value))
+		if err != nil {
+			return nil, &ConfigError{Line: line, Err: err}
+		}
+
+		err = current.setValue(strings.TrimSpace(key), values, line)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+// parseINIValue parses a scalar or a [a, b] array, where elements may be
+// quoted and unquoted scalars may be followed by a # comment.
+func parseINIValue(s string) ([]string, error) {
+	if !strings.HasPrefix(s, "[") {
+		value, rest, err := parseINIScalar(s, "#")
+		if err != nil {
+			return nil, err
+		}
+
+		if rest != "" && rest[0] != '#' {
+			return nil, fmt.Errorf("unexpected %q after value", rest)
+		}
+		return []string{value}, nil
+	}
+
+	var values []string
+	rest := strings.TrimSpace(s[1:])
+	for !strings.HasPrefix(rest, "]") {
+		value, next, err := parseINIScalar(rest, ",]")
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+
+		rest = strings.TrimPrefix(next, ",")
+		rest = strings.TrimSpace(rest)
+		if rest == "" {
+			return nil, fmt.Errorf("unterminated array %q", s)
+		}
+	}
+
+	rest = strings.TrimSpace(rest[1:])
+	if rest != "" && rest[0] != '#' {
+		return nil, fmt.Errorf("unexpected %q after array", rest)
+	}
+	return values, nil
+}
+
+// parseINIScalar parses a quoted or bare scalar at the start of s, a bare one
+// ending at any of the stop characters, and returns it with the remaining
+// text.
+func parseINIScalar(s, stop string) (string, string, error) {
+	switch {
+	case strings.HasPrefix(s, `"`):
+		end := 1
+		for end < len(s) && s[end] != '"' {
+			if s[end] == '\\' {
+				end++
+			}
+			end++
+		}
+
+		if end >= len(s) {
+			return "", "", fmt.Errorf("unterminated string %s", s)
+		}
+
+		value, err := strconv.Unquote(s[:end+1])
+		if err != nil {
+			return "", "", fmt.Errorf("invalid string %s", s[:end+1])
+		}
+		return value, strings.TrimSpace(s[end+1:]), nil
+	case strings.HasPrefix(s, "'"):
+		end := strings.IndexByte(s[1:], '\'')
+		if end < 0 {
+			return "", "", fmt.Errorf("unterminated string %s", s)
+		}
+		return s[1 : end+1], strings.TrimSpace(s[end+2:]), nil
+	default:
+		end := strings.IndexAny(s, stop)
+		if end < 0 {
+			end = len(s)
+		}
+		return strings.TrimSpace(s[:end]), s[end:], nil
+	}
+}
@@ -0,0 +1,126 @@
+package structcli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+type jsonFormat struct{}
+
+func (jsonFormat) Decode(data []byte) (*Config, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	if tok != json.Delim('{') {
+		return nil, errors.New("expected a JSON object")
+	}
+
+	config, err := decodeJSONObject(dec, data, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, &ConfigError{Line: lineAt(data, dec.InputOffset()), Err: errors.New("unexpected data after the JSON object")}
+	}
+	return config, nil
+}
+
+func decodeJSONObject(dec *json.Decoder, data []byte, line int) (*Config, error) {
+	config := newConfig(line)
+
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		key := tok.(string)
+		line := lineAt(data, dec.InputOffset())
+
+		tok, err = dec.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		switch tok {
+		case json.Delim('{'):
+			if _, ok := config.Sections[key]; ok {
+				return nil, &ConfigError{Line: line, Err: fmt.Errorf("duplicated key %q", key)}
+			}
+
+			section, err := decodeJSONObject(dec, data, line)
+			if err != nil {
+				return nil, err
+			}
+			config.Sections[key] = section
+		case json.Delim('['):
+			var values []string
+			for dec.More() {
+				tok, err = dec.Token()
+				if err != nil {
+					return nil, err
+				}
+
+				value, ok := jsonScalar(tok)
+				if !ok {
+					return nil, &ConfigError{Line: line, Err: fmt.Errorf("array %q must only hold scalar values", key)}
+				}
+				values = append(values, value)
+			}
+
+			// Consume the closing bracket.
+			_, err = dec.Token()
+			if err != nil {
+				return nil, err
+			}
+
+			err = config.setValue(key, values, line)
+			if err != nil {
+				return nil, err
+			}
+		case nil:
+			// A null value leaves the option unset.
+		default:
+			value, _ := jsonScalar(tok)
+			err = config.setValue(key, []string{value}, line)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Consume the closing brace.
+	_, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func jsonScalar(tok json.Token) (string, bool) {
+	switch tok := tok.(type) {
+	case string:
+		return tok, true
+	case json.Number:
+		return tok.String(), true
+	case bool:
+		return strconv.FormatBool(tok), true
+	default:
+		return "", false
+	}
+}
+
+// lineAt returns the 1-based line of the given byte offset.
+func lineAt(data []byte, offset int64) int {
+	return bytes.Count(data[:offset], []byte{'\n'}) + 1
+}
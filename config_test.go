@@ -0,0 +1,224 @@
+package structcli_test
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tsingmuhe/structcli"
+)
+
+type ConfigCommand struct {
+	Config  string            `short:"-c" long:"--config" default:"testdata/missing.conf"`
+	Port    int               `long:"--port" env:"CONFIG_PORT" default:"80"`
+	Host    string            `long:"--host" config:"server_host"`
+	Verbose bool              `long:"--verbose,negatable"`
+	Tags    []string          `long:"--tag"`
+	Labels  map[string]string `long:"--label"`
+
+	Deploy *ConfigDeployCommand `command:"deploy"`
+}
+
+type ConfigDeployCommand struct {
+	Target  string        `long:"--target"`
+	Timeout time.Duration `long:"--timeout"`
+}
+
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	err := os.WriteFile(path, []byte(content), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCommandLine_Config(t *testing.T) {
+	jsonPath := writeConfig(t, "app.json", `{
+  "port": 8080,
+  "server_host": "example.com",
+  "verbose": true,
+  "tag": ["a", "b"],
+  "label": {"env": "prod", "team": "core"},
+  "deploy": {
+    "target": "prod",
+    "timeout": "1m"
+  }
+}`)
+
+	iniPath := writeConfig(t, "app.conf", `# application settings
+port = 8080
+server_host = "example.com" # inline comment
+verbose = true
+tag = ["a", 'b']
+
+[label]
+env = prod
+team = core
+
+[deploy]
+target = prod
+timeout = 1m
+`)
+
+	for _, path := range []string{jsonPath, iniPath} {
+		cli := structcli.Create("cmd", "a test cmd", "1.0.0", new(ConfigCommand))
+		cli.SetConfig("--config", nil)
+
+		res, err := cli.Parse([]string{"--config", path, "--no-verbose", "deploy", "--target=staging"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		cmd := res.Command
+		if cmd.Port != 8080 || cmd.Host != "example.com" || cmd.Verbose || !reflect.DeepEqual(cmd.Tags, []string{"a", "b"}) {
+			t.Errorf("unexpected options from %s: %+v", path, cmd)
+		}
+
+		if !reflect.DeepEqual(cmd.Labels, map[string]string{"env": "prod", "team": "core"}) {
+			t.Errorf("unexpected map option from %s: %v", path, cmd.Labels)
+		}
+
+		if cmd.Deploy.Target != "staging" || cmd.Deploy.Timeout != time.Minute {
+			t.Errorf("unexpected subcommand options from %s: %+v", path, cmd.Deploy)
+		}
+	}
+
+	t.Setenv("CONFIG_PORT", "9090")
+
+	cli := structcli.Create("cmd", "a test cmd", "1.0.0", new(ConfigCommand))
+	cli.SetConfig("--config", structcli.JSONConfig)
+
+	res, err := cli.Parse([]string{"-c", jsonPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Command.Port != 9090 {
+		t.Errorf("environment variables must take precedence over the config file, got %d", res.Command.Port)
+	}
+}
+
+func TestCommandLine_ConfigErrors(t *testing.T) {
+	tests := map[string]string{
+		"unknown.json":  `{"unknown": 1}`,
+		"invalid.json":  `{"port": "http"}`,
+		"trailing.json": `{"port": 5} garbage`,
+		"section.conf":  "[other]\nkey = 1\n",
+		"syntax.conf":   "port\n",
+		"missing.conf":  "",
+	}
+
+	for name, content := range tests {
+		path := filepath.Join(t.TempDir(), name)
+		if name != "missing.conf" {
+			path = writeConfig(t, name, content)
+		}
+
+		cli := structcli.Create("cmd", "a test cmd", "1.0.0", new(ConfigCommand))
+		cli.SetConfig("--config", nil)
+
+		_, err := cli.Parse([]string{"--config", path})
+		if err == nil {
+			t.Errorf("expected error for %s", name)
+		} else if name != "missing.conf" && !strings.Contains(err.Error(), path) && !strings.Contains(err.Error(), "line") {
+			t.Errorf("error for %s must point at the file: %v", name, err)
+		}
+
+		var configErr *structcli.ConfigError
+		if name != "invalid.json" && (!errors.As(err, &configErr) || configErr.File != path) {
+			t.Errorf("expected ConfigError for %s, got %v", name, err)
+		}
+	}
+
+	var configErr *structcli.ConfigError
+	cli := structcli.Create("cmd", "a test cmd", "1.0.0", new(ConfigCommand))
+	cli.SetConfig("--config", nil)
+
+	_, err := cli.Parse([]string{"-c", writeConfig(t, "app.conf", "port = 1\n\n[deploy]\nunknown = 1\n")})
+	if !errors.As(err, &configErr) || configErr.Line != 4 || !reflect.DeepEqual(configErr.Command, []string{"cmd", "deploy"}) {
+		t.Errorf("unexpected error for an unknown key: %v", err)
+	}
+
+	for path, code := range map[string]int{
+		writeConfig(t, "unknown.conf", "unknown = 1\n"): 2,
+		filepath.Join(t.TempDir(), "missing.conf"):      1,
+	} {
+		var codes []int
+		cli := structcli.Create("cmd", "a test cmd", "1.0.0", new(ConfigCommand))
+		cli.SetConfig("--config", nil)
+		cli.SetErrorHandling(structcli.ExitOnError)
+		cli.SetErrOutput(io.Discard)
+		cli.SetExitFunc(func(code int) { codes = append(codes, code) })
+
+		_, _ = cli.Parse([]string{"-c", path})
+		if !reflect.DeepEqual(codes, []int{code}) {
+			t.Errorf("expected exit status %d for %s, got %v", code, path, codes)
+		}
+	}
+
+	cli = structcli.Create("cmd", "a test cmd", "1.0.0", new(ConfigCommand))
+	cli.SetConfig("--config", nil)
+
+	_, err = cli.Parse(nil)
+	if err != nil {
+		t.Fatalf("a missing default config file must be ignored: %v", err)
+	}
+}
+
+func TestResult_Settings(t *testing.T) {
+	path := writeConfig(t, "app.conf", "server_host = example.com\n\n[deploy]\ntimeout = 1m\n")
+	t.Setenv("CONFIG_PORT", "9090")
+
+	cli := structcli.Create("cmd", "a test cmd", "1.0.0", new(ConfigCommand))
+	cli.SetConfig("--config", nil)
+
+	res, err := cli.Parse([]string{"--config", path, "--tag=a", "--tag", "b,c", "deploy"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]structcli.Origin{
+		"--config":  {Source: structcli.SourceArgs},
+		"--port":    {Source: structcli.SourceEnv, Env: "CONFIG_PORT"},
+		"--host":    {Source: structcli.SourceConfig, File: path, Line: 1},
+		"--verbose": {Source: structcli.SourceUnset},
+		"--tag":     {Source: structcli.SourceArgs},
+		"--timeout": {Source: structcli.SourceConfig, File: path, Line: 4},
+	}
+
+	for _, s := range res.Settings {
+		origin, ok := want[s.Name]
+		if ok && s.Origin != origin {
+			t.Errorf("unexpected origin for %s: %v, want %v", s.Name, s.Origin, origin)
+		}
+	}
+
+	var b strings.Builder
+	err = res.WriteSettings(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dump := regexp.MustCompile(` +`).ReplaceAllString(b.String(), " ")
+	for _, line := range []string{
+		"[cmd]\n",
+		"--port = 9090 # environment variable CONFIG_PORT\n",
+		"--host = example.com # config file " + path + ":1\n",
+		"--tag = a,\"b,c\" # command line\n",
+		"[cmd deploy]\n",
+		"--target = # unset\n",
+	} {
+		if !strings.Contains(dump, line) {
+			t.Errorf("settings dump does not contain %q:\n%s", line, b.String())
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package structcli
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+
+	"github.com/tsingmuhe/structcli/flag"
+)
+
+// A SpecError reports a command struct that cannot be turned into a command
+// line, e.g. because of an invalid tag. Field is empty when the error is not
+// about a single field.
+type SpecError struct {
+	Command []string
+	Field   string
+	Err     error
+}
+
+func (e *SpecError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *SpecError) Unwrap() error {
+	return e.Err
+}
+
+// An UnknownOptionError reports an option that is not defined by the command.
+// Option is given as typed, e.g. "--verbose" or "-v". Suggestions lists the
+// options with a close name, including those of the parent commands and
+// subcommands.
+type UnknownOptionError struct {
+	Command     []string
+	Option      string
+	Suggestions []Suggestion
+}
+
+func (e *UnknownOptionError) Error() string {
+	return fmt.Sprintf("unknown option `%s`", e.Option) + formatSuggestions(e.Command, e.Suggestions)
+}
+
+// A MissingArgumentError reports an option given without its value.
+type MissingArgumentError struct {
+	Command []string
+	Option  string
+}
+
+func (e *MissingArgumentError) Error() string {
+	return fmt.Sprintf("option `%s` needs an argument", e.Option)
+}
+
+// An InvalidValueError reports a value rejected by an option or a positional.
+// Name is the option name or the positional placeholder, Origin tells where
+// the value came from and Err is the error returned when setting it.
+type InvalidValueError struct {
+	Command []string
+	Name    string
+	Value   string
+	Origin  Origin
+	Err     error
+}
+
+func (e *InvalidValueError) Error() string {
+	if e.Origin.Source == SourceUnset {
+		return fmt.Sprintf("%s %v", describeName(e.Name), e.Err)
+	}
+	if e.Origin.Source == SourceArgs {
+		return fmt.Sprintf("invalid value %q for %s: %v", e.Value, describeName(e.Name), e.Err)
+	}
+	return fmt.Sprintf("invalid value %q for %s from %s: %v", e.Value, describeName(e.Name), e.Origin, e.Err)
+}
+
+func (e *InvalidValueError) Unwrap() error {
+	return e.Err
+}
+
+// A MissingRequiredError reports a required option or positional that was not
+// set. Name is the option name or the positional placeholder.
+type MissingRequiredError struct {
+	Command []string
+	Name    string
+}
+
+func (e *MissingRequiredError) Error() string {
+	return fmt.Sprintf("%s is required", describeName(e.Name))
+}
+
+// An UnknownCommandError reports a subcommand name that is not defined.
+// Suggestions lists the subcommands with a close name.
+type UnknownCommandError struct {
+	Command     []string
+	Name        string
+	Suggestions []Suggestion
+}
+
+func (e *UnknownCommandError) Error() string {
+	return fmt.Sprintf("unknown command `%s`", e.Name) + formatSuggestions(e.Command, e.Suggestions)
+}
+
+// An UnexpectedArgumentError reports an argument left over once every
+// positional of the command has been assigned.
+type UnexpectedArgumentError struct {
+	Command []string
+	Arg     string
+}
+
+func (e *UnexpectedArgumentError) Error() string {
+	return fmt.Sprintf("unexpected argument `%s`", e.Arg)
+}
+
+// A ValidationError reports every value violating the validation tags of its
+// option or positional, once all sources have been merged.
+type ValidationError struct {
+	Errors []*InvalidValueError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func (e *ValidationError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, err := range e.Errors {
+		errs[i] = err
+	}
+	return errs
+}
+
+// A ConfigError reports a configuration file that cannot be read or decoded,
+// or holding a key or a section that matches no option or subcommand. Line is
+// 0 when the error is not about a single line.
+type ConfigError struct {
+	Command []string
+	File    string
+	Line    int
+	Err     error
+}
+
+func (e *ConfigError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("config file %s:%d: %v", e.File, e.Line, e.Err)
+	}
+	return fmt.Sprintf("config file %s: %v", e.File, e.Err)
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// isUsageError reports whether err is caused by the arguments given to the
+// command line, or the configuration file they point at, as opposed to a
+// broken command spec or an I/O failure.
+func isUsageError(err error) bool {
+	var (
+		unknownOptionErr  *UnknownOptionError
+		missingErr        *MissingArgumentError
+		invalidErr        *InvalidValueError
+		requiredErr       *MissingRequiredError
+		unknownCommandErr *UnknownCommandError
+		unexpectedErr     *UnexpectedArgumentError
+		configErr         *ConfigError
+		pathErr           *fs.PathError
+	)
+
+	return errors.As(err, &unknownOptionErr) ||
+		errors.As(err, &missingErr) ||
+		errors.As(err, &invalidErr) ||
+		errors.As(err, &requiredErr) ||
+		errors.As(err, &unknownCommandErr) ||
+		errors.As(err, &unexpectedErr) ||
+		errors.As(err, &configErr) && !errors.As(err, &pathErr)
+}
+
+// describeName returns how an option or a positional is referred to in error
+// messages. Positional placeholders never start with '-'.
+func describeName(name string) string {
+	if strings.HasPrefix(name, "-") {
+		return "option `" + name + "`"
+	}
+	return "positional `" + name + "`"
+}
+
+// newParseError converts an error returned by the flags of c into one of the
+// errors above.
+func (c *commandSpec) newParseError(err error) error {
+	var (
+		syntaxErr  *flag.SyntaxError
+		unknownErr *flag.UnknownFlagError
+		missingErr *flag.MissingArgumentError
+		invalidErr *flag.InvalidValueError
+	)
+
+	switch {
+	case errors.As(err, &syntaxErr):
+		return &UnknownOptionError{Command: c.path(), Option: syntaxErr.Arg, Suggestions: c.suggestOptions(syntaxErr.Arg)}
+	case errors.As(err, &unknownErr):
+		return &UnknownOptionError{Command: c.path(), Option: unknownErr.Flag, Suggestions: c.suggestOptions(unknownErr.Flag)}
+	case errors.As(err, &missingErr):
+		return &MissingArgumentError{Command: c.path(), Option: missingErr.Flag}
+	case errors.As(err, &invalidErr):
+		return &InvalidValueError{
+			Command: c.path(),
+			Name:    invalidErr.Flag,
+			Value:   invalidErr.Value,
+			Origin:  Origin{Source: SourceArgs},
+			Err:     invalidErr.Err,
+		}
+	default:
+		return err
+	}
+}
@@ -0,0 +1,76 @@
+package structcli
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/tsingmuhe/structcli/flag"
+)
+
+// fieldSpec holds what options and positionals share: the value stored into
+// the struct field along with its default, choices and validation, and where
+// it came from.
+type fieldSpec struct {
+	defaultValue string
+	hasDefault   bool
+	required     bool
+
+	choices   []string
+	value     flag.Value
+	completer Completer
+	validator *validator
+	origin    Origin
+}
+
+// newFieldSpec returns the fieldSpec of the struct field v. kind is "option"
+// or "positional", as used in error messages.
+func newFieldSpec(sf *structField, v reflect.Value, kind string) (fieldSpec, error) {
+	required, err := sf.isRequired()
+	if err != nil {
+		return fieldSpec{}, err
+	}
+
+	choices := getChoices(sf)
+	err = validateChoices(sf, choices)
+	if err != nil {
+		return fieldSpec{}, err
+	}
+
+	validator, err := newValidator(sf, v)
+	if err != nil {
+		return fieldSpec{}, err
+	}
+
+	value := newFieldValue(sf, v)
+	defaultValue, hasDefault, err := getDefaultValue(sf, value)
+	if err != nil {
+		return fieldSpec{}, err
+	}
+
+	if required && hasDefault {
+		return fieldSpec{}, fmt.Errorf("%s field `%s` cannot be both required and have a default", kind, sf.Name)
+	}
+
+	return fieldSpec{
+		defaultValue: defaultValue,
+		hasDefault:   hasDefault,
+		required:     required,
+		choices:      choices,
+		value:        value,
+		completer:    getCompleter(sf),
+		validator:    validator,
+	}, nil
+}
+
+func (f *fieldSpec) applyDefault() (Origin, error) {
+	if !f.hasDefault {
+		return Origin{}, nil
+	}
+
+	// The default tag has been validated when building the spec.
+	err := f.value.Set(f.defaultValue)
+	if err != nil {
+		return Origin{}, err
+	}
+	return Origin{Source: SourceDefault}, nil
+}
@@ -0,0 +1,28 @@
+package flag
+
+import (
+	"errors"
+	"time"
+)
+
+type durationValue time.Duration
+
+func newDurationValue(val time.Duration, p *time.Duration) *durationValue {
+	*p = val
+	return (*durationValue)(p)
+}
+
+func (d *durationValue) String() string { return (*time.Duration)(d).String() }
+
+func (d *durationValue) Set(s string) error {
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return errors.New("expected a duration such as 300ms or 1h30m")
+	}
+	*d = durationValue(v)
+	return nil
+}
+
+func (f *FlagSet) DurationVar(p *time.Duration, value time.Duration, shorthand, name, description string) {
+	f.Var(newDurationValue(value, p), shorthand, name, description)
+}
@@ -2,11 +2,13 @@ package flag
 
 import (
 	"errors"
+	"fmt"
 	"strconv"
 )
 
 var errParse = errors.New("parse error")
 var errRange = errors.New("value out of range")
+var errNoValue = errors.New("flag does not take a value")
 
 func numError(err error) error {
 	ne, ok := err.(*strconv.NumError)
@@ -24,3 +26,48 @@ func numError(err error) error {
 
 	return err
 }
+
+// A SyntaxError is returned by Parse for an argument that starts like a flag
+// but is malformed, such as "---x" or "-=x".
+type SyntaxError struct {
+	Arg string
+}
+
+func (e *SyntaxError) Error() string {
+	return "bad flag syntax: " + e.Arg
+}
+
+// An UnknownFlagError is returned for a flag that is not defined. Flag is
+// given with its dashes, e.g. "--verbose" or "-v".
+type UnknownFlagError struct {
+	Flag string
+}
+
+func (e *UnknownFlagError) Error() string {
+	return "flag provided but not defined: " + e.Flag
+}
+
+// A MissingArgumentError is returned for a flag given without its value.
+type MissingArgumentError struct {
+	Flag string
+}
+
+func (e *MissingArgumentError) Error() string {
+	return "flag needs an argument: " + e.Flag
+}
+
+// An InvalidValueError is returned when the Value of a flag rejects the
+// argument it was given. Err is the error returned by Value.Set.
+type InvalidValueError struct {
+	Flag  string
+	Value string
+	Err   error
+}
+
+func (e *InvalidValueError) Error() string {
+	return fmt.Sprintf("invalid value %q for flag %s: %v", e.Value, e.Flag, e.Err)
+}
+
+func (e *InvalidValueError) Unwrap() error {
+	return e.Err
+}
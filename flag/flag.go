@@ -1,18 +1,26 @@
 package flag
 
 import (
+	"encoding"
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"slices"
 	"strings"
+	"time"
 )
 
+// ErrHelp is returned by Parse when -h or --help is given but not defined.
+var ErrHelp = errors.New("flag: help requested")
+
 type Flag struct {
 	Shorthand   string
 	Name        string
 	Description string
 	DefValue    string
 	Value       Value
+	Changed     bool
 }
 
 type Value interface {
@@ -25,35 +33,196 @@ type BoolValue interface {
 	IsBool() bool
 }
 
+// A SwitchValue is a BoolValue that is only set by giving its flag, such as
+// the negated form of a boolean flag. An explicit value is rejected.
+type SwitchValue interface {
+	BoolValue
+	IsSwitch() bool
+}
+
+func isSwitch(v Value) bool {
+	sv, ok := v.(SwitchValue)
+	return ok && sv.IsSwitch()
+}
+
+// NewValue returns a Value storing into p, which must be a pointer to one of
+// the types supported by the XxxVar methods, a Value or an
+// encoding.TextUnmarshaler. It returns nil for any other type.
+func NewValue(p any) Value {
+	switch p := p.(type) {
+	case Value:
+		return p
+	case *bool:
+		return (*boolValue)(p)
+	case *string:
+		return (*stringValue)(p)
+	case *int:
+		return (*intValue)(p)
+	case *int8:
+		return (*int8Value)(p)
+	case *int16:
+		return (*int16Value)(p)
+	case *int32:
+		return (*int32Value)(p)
+	case *int64:
+		return (*int64Value)(p)
+	case *uint:
+		return (*uintValue)(p)
+	case *uint8:
+		return (*uint8Value)(p)
+	case *uint16:
+		return (*uint16Value)(p)
+	case *uint32:
+		return (*uint32Value)(p)
+	case *uint64:
+		return (*uint64Value)(p)
+	case *float32:
+		return (*float32Value)(p)
+	case *float64:
+		return (*float64Value)(p)
+	case *time.Duration:
+		return (*durationValue)(p)
+	case *time.Time:
+		return NewTimeValue(p)
+	case encoding.TextUnmarshaler:
+		return textValue{p}
+	default:
+		return nil
+	}
+}
+
+// ErrorHandling defines how FlagSet.Parse behaves if the parse fails.
+type ErrorHandling int
+
+const (
+	// ContinueOnError returns the error.
+	ContinueOnError ErrorHandling = iota
+	// ExitOnError prints the error and the usage, then exits with status 2,
+	// or with status 0 after printing the usage when help is requested.
+	ExitOnError
+	// PanicOnError panics with the error. A help request is still returned
+	// as ErrHelp.
+	PanicOnError
+)
+
 type FlagSet struct {
-	name string
+	// Usage is called when an error occurs while parsing flags, or when help
+	// is requested, and the error handling is ExitOnError. It defaults to
+	// printing the flags to the output.
+	Usage func()
+
+	name          string
+	errorHandling ErrorHandling
+	output        io.Writer
+	errOutput     io.Writer
+	exit          func(code int)
 
 	formal     map[string]*Flag
 	shorthands map[byte]*Flag
 
-	parsed bool
-	actual map[string]*Flag
-	args   []string
+	interspersed  bool
+	parsed        bool
+	args          []string
+	argsLenAtDash int
+}
+
+func NewFlagSet(name string, errorHandling ErrorHandling) *FlagSet {
+	f := &FlagSet{
+		name:          name,
+		errorHandling: errorHandling,
+		interspersed:  true,
+		argsLenAtDash: -1,
+	}
+	f.Usage = f.defaultUsage
+	return f
+}
+
+// ErrorHandling returns the error handling behavior of the flag set.
+func (f *FlagSet) ErrorHandling() ErrorHandling {
+	return f.errorHandling
+}
+
+// Output returns the destination for the usage printed when help is
+// requested, os.Stdout by default.
+func (f *FlagSet) Output() io.Writer {
+	if f.output == nil {
+		return os.Stdout
+	}
+	return f.output
+}
+
+// SetOutput sets the destination for the usage printed when help is
+// requested. If w is nil, os.Stdout is used.
+func (f *FlagSet) SetOutput(w io.Writer) {
+	f.output = w
+}
+
+// ErrOutput returns the destination for error messages and the usage printed
+// along with them, os.Stderr by default.
+func (f *FlagSet) ErrOutput() io.Writer {
+	if f.errOutput == nil {
+		return os.Stderr
+	}
+	return f.errOutput
+}
+
+// SetErrOutput sets the destination for error messages and the usage printed
+// along with them. If w is nil, os.Stderr is used.
+func (f *FlagSet) SetErrOutput(w io.Writer) {
+	f.errOutput = w
+}
+
+// SetExitFunc sets the function called to exit under ExitOnError, os.Exit by
+// default.
+func (f *FlagSet) SetExitFunc(exit func(code int)) {
+	f.exit = exit
+}
+
+// SetInterspersed controls whether flags may follow non-flag arguments. When
+// disabled, parsing stops at the first non-flag argument.
+func (f *FlagSet) SetInterspersed(interspersed bool) {
+	f.interspersed = interspersed
+}
+
+func (f *FlagSet) Lookup(name string) *Flag {
+	return f.formal[name]
+}
+
+func (f *FlagSet) ShorthandLookup(shorthand string) *Flag {
+	if len(shorthand) != 1 {
+		return nil
+	}
+	return f.shorthands[shorthand[0]]
+}
+
+// Changed reports whether the flag with the given name was set.
+func (f *FlagSet) Changed(name string) bool {
+	flag, ok := f.formal[name]
+	return ok && flag.Changed
 }
 
 func (f *FlagSet) Var(value Value, shorthand, name, description string) {
 	if len(shorthand) > 1 {
-		panic(f.sprintf("flag shorthand `%s` is more than one ASCII character", shorthand))
+		panic(fmt.Sprintf("flag shorthand `%s` is more than one ASCII character", shorthand))
 	}
 
 	if len(shorthand) == 1 {
 		if shorthand == "-" {
-			panic(f.sprintf("flag shorthand `%s` begins with -", shorthand))
+			panic(fmt.Sprintf("flag shorthand `%s` begins with -", shorthand))
 		} else if shorthand == "=" {
-			panic(f.sprintf("flag shorthand `%s` contains =", shorthand))
+			panic(fmt.Sprintf("flag shorthand `%s` contains =", shorthand))
 		}
 	}
 
+	if len(shorthand) == 0 && len(name) == 0 {
+		panic("flag has neither a name nor a shorthand")
+	}
+
 	// Flag must not begin "-" or contain "=".
 	if strings.HasPrefix(name, "-") {
-		panic(f.sprintf("flag `%s` begins with -", name))
+		panic(fmt.Sprintf("flag `%s` begins with -", name))
 	} else if strings.Contains(name, "=") {
-		panic(f.sprintf("flag `%s` contains =", name))
+		panic(fmt.Sprintf("flag `%s` contains =", name))
 	}
 
 	f.addFlag(&Flag{
@@ -61,39 +230,42 @@ func (f *FlagSet) Var(value Value, shorthand, name, description string) {
 		Name:        name,
 		Description: description,
 		DefValue:    value.String(),
+		Value:       value,
 	})
 }
 
 func (f *FlagSet) addFlag(flag *Flag) {
-	_, alreadyThere := f.formal[flag.Name]
-	if alreadyThere {
-		var msg string
-		if f.name == "" {
-			msg = f.sprintf("flag redefined: --%s", flag.Name)
-		} else {
-			msg = f.sprintf("%s flag redefined: --%s", f.name, flag.Name)
+	if flag.Name != "" {
+		_, alreadyThere := f.formal[flag.Name]
+		if alreadyThere {
+			var msg string
+			if f.name == "" {
+				msg = fmt.Sprintf("flag redefined: --%s", flag.Name)
+			} else {
+				msg = fmt.Sprintf("%s flag redefined: --%s", f.name, flag.Name)
+			}
+			panic(msg)
 		}
-		panic(msg)
-	}
 
-	if f.formal == nil {
-		f.formal = make(map[string]*Flag)
-	}
+		if f.formal == nil {
+			f.formal = make(map[string]*Flag)
+		}
 
-	f.formal[flag.Name] = flag
+		f.formal[flag.Name] = flag
+	}
 
 	if flag.Shorthand == "" {
 		return
 	}
 
 	short := flag.Shorthand[0]
-	_, alreadyThere = f.shorthands[short]
+	_, alreadyThere := f.shorthands[short]
 	if alreadyThere {
 		var msg string
 		if f.name == "" {
-			msg = f.sprintf("flag redefined: -%s", flag.Shorthand)
+			msg = fmt.Sprintf("flag redefined: -%s", flag.Shorthand)
 		} else {
-			msg = f.sprintf("%s flag redefined: -%s", f.name, flag.Shorthand)
+			msg = fmt.Sprintf("%s flag redefined: -%s", f.name, flag.Shorthand)
 		}
 		panic(msg)
 	}
@@ -108,7 +280,7 @@ func (f *FlagSet) addFlag(flag *Flag) {
 func (f *FlagSet) Set(name, value string) error {
 	flag, ok := f.formal[name]
 	if !ok {
-		return fmt.Errorf("no such flag: --%v", name)
+		return &UnknownFlagError{Flag: "--" + name}
 	}
 
 	return f.set(flag, value)
@@ -120,17 +292,41 @@ func (f *FlagSet) set(flag *Flag, value string) error {
 		return err
 	}
 
-	if f.actual == nil {
-		f.actual = make(map[string]*Flag)
+	flag.Changed = true
+	return nil
+}
+
+// Parse parses flags from args, which must not include the program name, and
+// handles a failure according to the error handling of the set.
+func (f *FlagSet) Parse(args []string) error {
+	err := f.parse(args)
+	if err == nil {
+		return nil
 	}
 
-	f.actual[flag.Name] = flag
-	return nil
+	switch f.errorHandling {
+	case ExitOnError:
+		if err == ErrHelp {
+			f.usage()
+			f.doExit(0)
+			break
+		}
+
+		_, _ = fmt.Fprintln(f.ErrOutput(), err)
+		f.usageTo(f.ErrOutput())
+		f.doExit(2)
+	case PanicOnError:
+		if err != ErrHelp {
+			panic(err)
+		}
+	}
+	return err
 }
 
-func (f *FlagSet) Parse(args []string) (err error) {
+func (f *FlagSet) parse(args []string) (err error) {
 	f.parsed = true
 	f.args = make([]string, 0, len(args))
+	f.argsLenAtDash = -1
 
 	for len(args) > 0 {
 		arg0 := args[0]
@@ -138,11 +334,16 @@ func (f *FlagSet) Parse(args []string) (err error) {
 
 		if len(arg0) < 2 || arg0[0] != '-' {
 			f.args = append(f.args, arg0)
+			if !f.interspersed {
+				f.args = append(f.args, args...)
+				break
+			}
 			continue
 		}
 
 		if arg0[1] == '-' {
 			if len(arg0) == 2 {
+				f.argsLenAtDash = len(f.args)
 				f.args = append(f.args, args...)
 				break
 			}
@@ -163,7 +364,7 @@ func (f *FlagSet) Parse(args []string) (err error) {
 func (f *FlagSet) parseLong(arg0 string, args []string) ([]string, error) {
 	name := arg0[2:]
 	if len(name) == 0 || name[0] == '-' || name[0] == '=' {
-		return args, f.failf("bad flag syntax: %s", arg0)
+		return args, &SyntaxError{Arg: arg0}
 	}
 
 	value, hasValue := "", false
@@ -173,17 +374,23 @@ func (f *FlagSet) parseLong(arg0 string, args []string) ([]string, error) {
 
 	flag, ok := f.formal[name]
 	if !ok {
-		return args, f.failf("flag provided but not defined: --%s", name)
+		if name == "help" {
+			return args, ErrHelp
+		}
+		return args, &UnknownFlagError{Flag: "--" + name}
 	}
 
 	if fv, ok := flag.Value.(BoolValue); ok && fv.IsBool() {
 		if hasValue {
+			if isSwitch(flag.Value) {
+				return args, &InvalidValueError{Flag: "--" + name, Value: value, Err: errNoValue}
+			}
 			if err := f.set(flag, value); err != nil {
-				return args, f.failf("invalid boolean value %q for --%s", value, name)
+				return args, &InvalidValueError{Flag: "--" + name, Value: value, Err: err}
 			}
 		} else {
 			if err := f.set(flag, "true"); err != nil {
-				return args, f.failf("invalid boolean flag: --%s", name)
+				return args, &InvalidValueError{Flag: "--" + name, Value: "true", Err: err}
 			}
 		}
 	} else {
@@ -193,11 +400,11 @@ func (f *FlagSet) parseLong(arg0 string, args []string) ([]string, error) {
 		}
 
 		if !hasValue {
-			return args, f.failf("flag needs an argument: --%s", name)
+			return args, &MissingArgumentError{Flag: "--" + name}
 		}
 
 		if err := f.set(flag, value); err != nil {
-			return args, f.failf("invalid value %q for flag --%s", value, name)
+			return args, &InvalidValueError{Flag: "--" + name, Value: value, Err: err}
 		}
 	}
 
@@ -207,7 +414,7 @@ func (f *FlagSet) parseLong(arg0 string, args []string) ([]string, error) {
 func (f *FlagSet) parseShort(arg0 string, args []string) ([]string, error) {
 	name := arg0[1:]
 	if len(name) == 0 || name[0] == '-' || name[0] == '=' {
-		return args, f.failf("bad flag syntax: %s", arg0)
+		return args, &SyntaxError{Arg: arg0}
 	}
 
 	value, hasValue := "", false
@@ -219,19 +426,25 @@ func (f *FlagSet) parseShort(arg0 string, args []string) ([]string, error) {
 		short := name[i]
 		flag, ok := f.shorthands[short]
 		if !ok {
-			return args, f.failf("flag provided but not defined: -%s", string(short))
+			if short == 'h' {
+				return args, ErrHelp
+			}
+			return args, &UnknownFlagError{Flag: "-" + string(short)}
 		}
 
 		isLast := i == len(name)-1
 		if isLast {
 			if fv, ok := flag.Value.(BoolValue); ok && fv.IsBool() {
 				if hasValue {
+					if isSwitch(flag.Value) {
+						return args, &InvalidValueError{Flag: "-" + string(short), Value: value, Err: errNoValue}
+					}
 					if err := f.set(flag, value); err != nil {
-						return args, f.failf("invalid boolean value %q for -%s", value, string(short))
+						return args, &InvalidValueError{Flag: "-" + string(short), Value: value, Err: err}
 					}
 				} else {
 					if err := f.set(flag, "true"); err != nil {
-						return args, f.failf("invalid boolean flag: -%s", string(short))
+						return args, &InvalidValueError{Flag: "-" + string(short), Value: "true", Err: err}
 					}
 				}
 			} else {
@@ -241,20 +454,20 @@ func (f *FlagSet) parseShort(arg0 string, args []string) ([]string, error) {
 				}
 
 				if !hasValue {
-					return args, f.failf("flag needs an argument: -%s", string(short))
+					return args, &MissingArgumentError{Flag: "-" + string(short)}
 				}
 
 				if err := f.set(flag, value); err != nil {
-					return args, f.failf("invalid value %q for flag -%s", value, string(short))
+					return args, &InvalidValueError{Flag: "-" + string(short), Value: value, Err: err}
 				}
 			}
 		} else {
 			if fv, ok := flag.Value.(BoolValue); ok && fv.IsBool() {
 				if err := f.set(flag, "true"); err != nil {
-					return args, f.failf("invalid boolean flag: -%s", string(short))
+					return args, &InvalidValueError{Flag: "-" + string(short), Value: "true", Err: err}
 				}
 			} else {
-				return args, f.failf("flag needs an argument: -%s", string(short))
+				return args, &MissingArgumentError{Flag: "-" + string(short)}
 			}
 		}
 	}
@@ -266,13 +479,81 @@ func (f *FlagSet) NArg() int { return len(f.args) }
 
 func (f *FlagSet) Args() []string { return f.args }
 
-func (f *FlagSet) failf(format string, a ...any) error {
-	msg := f.sprintf(format, a...)
-	return errors.New(msg)
+// ArgsLenAtDash returns the number of arguments that preceded the "--"
+// terminator, or -1 if it was not present.
+func (f *FlagSet) ArgsLenAtDash() int { return f.argsLenAtDash }
+
+// PrintDefaults prints the flags of the set to Output, sorted by name.
+func (f *FlagSet) PrintDefaults() {
+	var flags []*Flag
+	for _, flag := range f.formal {
+		flags = append(flags, flag)
+	}
+	for _, flag := range f.shorthands {
+		if flag.Name == "" {
+			flags = append(flags, flag)
+		}
+	}
+
+	slices.SortFunc(flags, func(a, b *Flag) int {
+		return strings.Compare(a.Name+a.Shorthand, b.Name+b.Shorthand)
+	})
+
+	var b strings.Builder
+	for _, flag := range flags {
+		b.WriteString("  ")
+		if flag.Shorthand != "" {
+			b.WriteString("-" + flag.Shorthand)
+			if flag.Name != "" {
+				b.WriteString(", ")
+			}
+		}
+		if flag.Name != "" {
+			b.WriteString("--" + flag.Name)
+		}
+		if fv, ok := flag.Value.(BoolValue); !ok || !fv.IsBool() {
+			b.WriteString(" value")
+		}
+		if flag.Description != "" {
+			b.WriteString("\n    \t" + strings.ReplaceAll(flag.Description, "\n", "\n    \t"))
+		}
+		b.WriteString("\n")
+	}
+
+	_, _ = io.WriteString(f.Output(), b.String())
 }
 
-func (f *FlagSet) sprintf(format string, a ...any) string {
-	msg := fmt.Sprintf(format, a...)
-	_, _ = fmt.Fprintln(os.Stderr, msg)
-	return msg
+func (f *FlagSet) defaultUsage() {
+	if f.name == "" {
+		_, _ = fmt.Fprintf(f.Output(), "Usage:\n")
+	} else {
+		_, _ = fmt.Fprintf(f.Output(), "Usage of %s:\n", f.name)
+	}
+	f.PrintDefaults()
+}
+
+func (f *FlagSet) usage() {
+	if f.Usage == nil {
+		f.defaultUsage()
+	} else {
+		f.Usage()
+	}
+}
+
+// usageTo calls the usage with the output temporarily set to w, so that the
+// usage printed after an error goes along with the error message.
+func (f *FlagSet) usageTo(w io.Writer) {
+	output := f.output
+	f.output = w
+	defer func() { f.output = output }()
+
+	f.usage()
+}
+
+func (f *FlagSet) doExit(code int) {
+	if f.exit == nil {
+		os.Exit(code)
+	} else {
+		f.exit(code)
+	}
 }
@@ -0,0 +1,115 @@
+package flag_test
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tsingmuhe/structcli/flag"
+)
+
+func TestFlagSet_Parse(t *testing.T) {
+	var name string
+	var verbose, force bool
+
+	fs := flag.NewFlagSet("cmd", flag.ContinueOnError)
+	fs.StringVar(&name, "", "n", "name", "name")
+	fs.BoolVar(&verbose, false, "v", "verbose", "verbose")
+	fs.BoolVar(&force, false, "f", "", "force")
+
+	err := fs.Parse([]string{"a", "-vf", "--name=x", "b", "--", "-c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if name != "x" || !verbose || !force {
+		t.Fatalf("unexpected values: %q %v %v", name, verbose, force)
+	}
+
+	if !fs.Changed("name") || !fs.ShorthandLookup("f").Changed {
+		t.Fatal("flags must be marked as changed")
+	}
+
+	if !reflect.DeepEqual(fs.Args(), []string{"a", "b", "-c"}) || fs.ArgsLenAtDash() != 2 {
+		t.Fatalf("unexpected args: %q %d", fs.Args(), fs.ArgsLenAtDash())
+	}
+}
+
+func TestFlagSet_SetInterspersed(t *testing.T) {
+	var verbose bool
+
+	fs := flag.NewFlagSet("cmd", flag.ContinueOnError)
+	fs.BoolVar(&verbose, false, "v", "verbose", "verbose")
+	fs.SetInterspersed(false)
+
+	err := fs.Parse([]string{"-v", "sub", "-v"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(fs.Args(), []string{"sub", "-v"}) || fs.ArgsLenAtDash() != -1 {
+		t.Fatalf("unexpected args: %q %d", fs.Args(), fs.ArgsLenAtDash())
+	}
+}
+
+func TestFlagSet_ParseErrors(t *testing.T) {
+	var port int
+	var verbose bool
+
+	fs := flag.NewFlagSet("cmd", flag.ContinueOnError)
+	fs.IntVar(&port, 0, "p", "port", "port")
+	fs.BoolVar(&verbose, false, "v", "verbose", "verbose")
+
+	var unknownErr *flag.UnknownFlagError
+	err := fs.Parse([]string{"-vx"})
+	if !errors.As(err, &unknownErr) || unknownErr.Flag != "-x" {
+		t.Errorf("expected an unknown flag error, got %v", err)
+	}
+
+	var missingErr *flag.MissingArgumentError
+	err = fs.Parse([]string{"--port"})
+	if !errors.As(err, &missingErr) || missingErr.Flag != "--port" {
+		t.Errorf("expected a missing argument error, got %v", err)
+	}
+
+	var invalidErr *flag.InvalidValueError
+	err = fs.Parse([]string{"-p", "x"})
+	if !errors.As(err, &invalidErr) || invalidErr.Flag != "-p" || invalidErr.Value != "x" || invalidErr.Err == nil {
+		t.Errorf("expected an invalid value error, got %v", err)
+	}
+
+	var syntaxErr *flag.SyntaxError
+	err = fs.Parse([]string{"---port"})
+	if !errors.As(err, &syntaxErr) || syntaxErr.Arg != "---port" {
+		t.Errorf("expected a syntax error, got %v", err)
+	}
+}
+
+func TestFlagSet_ExitOnError(t *testing.T) {
+	var port int
+	var out, errOut strings.Builder
+	var codes []int
+
+	fs := flag.NewFlagSet("cmd", flag.ExitOnError)
+	fs.IntVar(&port, 0, "p", "port", "listening port")
+	fs.SetOutput(&out)
+	fs.SetErrOutput(&errOut)
+	fs.SetExitFunc(func(code int) { codes = append(codes, code) })
+
+	_ = fs.Parse([]string{"--port=x"})
+	_ = fs.Parse([]string{"-h"})
+
+	usage := "Usage of cmd:\n  -p, --port value\n    \tlistening port\n"
+	if want := "invalid value \"x\" for flag --port: parse error\n" + usage; errOut.String() != want {
+		t.Errorf("unexpected error output:\n%s\nwant:\n%s", errOut.String(), want)
+	}
+
+	if out.String() != usage {
+		t.Errorf("unexpected output:\n%s\nwant:\n%s", out.String(), usage)
+	}
+
+	if !reflect.DeepEqual(codes, []int{2, 0}) {
+		t.Errorf("unexpected exit codes %v", codes)
+	}
+}
@@ -0,0 +1,25 @@
+package flag
+
+import "strconv"
+
+type float32Value float32
+
+func newFloat32Value(val float32, p *float32) *float32Value {
+	*p = val
+	return (*float32Value)(p)
+}
+
+func (f *float32Value) String() string { return strconv.FormatFloat(float64(*f), 'g', -1, 32) }
+
+func (f *float32Value) Set(s string) error {
+	v, err := strconv.ParseFloat(s, 32)
+	if err != nil {
+		err = numError(err)
+	}
+	*f = float32Value(v)
+	return err
+}
+
+func (f *FlagSet) Float32Var(p *float32, value float32, shorthand, name, description string) {
+	f.Var(newFloat32Value(value, p), shorthand, name, description)
+}
@@ -0,0 +1,25 @@
+package flag
+
+import "strconv"
+
+type int16Value int16
+
+func newInt16Value(val int16, p *int16) *int16Value {
+	*p = val
+	return (*int16Value)(p)
+}
+
+func (i *int16Value) String() string { return strconv.FormatInt(int64(*i), 10) }
+
+func (i *int16Value) Set(s string) error {
+	v, err := strconv.ParseInt(s, 0, 16)
+	if err != nil {
+		err = numError(err)
+	}
+	*i = int16Value(v)
+	return err
+}
+
+func (f *FlagSet) Int16Var(p *int16, value int16, shorthand, name, description string) {
+	f.Var(newInt16Value(value, p), shorthand, name, description)
+}
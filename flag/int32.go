@@ -0,0 +1,25 @@
+package flag
+
+import "strconv"
+
+type int32Value int32
+
+func newInt32Value(val int32, p *int32) *int32Value {
+	*p = val
+	return (*int32Value)(p)
+}
+
+func (i *int32Value) String() string { return strconv.FormatInt(int64(*i), 10) }
+
+func (i *int32Value) Set(s string) error {
+	v, err := strconv.ParseInt(s, 0, 32)
+	if err != nil {
+		err = numError(err)
+	}
+	*i = int32Value(v)
+	return err
+}
+
+func (f *FlagSet) Int32Var(p *int32, value int32, shorthand, name, description string) {
+	f.Var(newInt32Value(value, p), shorthand, name, description)
+}
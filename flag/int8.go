@@ -0,0 +1,25 @@
+package flag
+
+import "strconv"
+
+type int8Value int8
+
+func newInt8Value(val int8, p *int8) *int8Value {
+	*p = val
+	return (*int8Value)(p)
+}
+
+func (i *int8Value) String() string { return strconv.FormatInt(int64(*i), 10) }
+
+func (i *int8Value) Set(s string) error {
+	v, err := strconv.ParseInt(s, 0, 8)
+	if err != nil {
+		err = numError(err)
+	}
+	*i = int8Value(v)
+	return err
+}
+
+func (f *FlagSet) Int8Var(p *int8, value int8, shorthand, name, description string) {
+	f.Var(newInt8Value(value, p), shorthand, name, description)
+}
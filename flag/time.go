@@ -0,0 +1,53 @@
+package flag
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DefaultTimeLayouts are the layouts accepted by time values unless others
+// are given.
+var DefaultTimeLayouts = []string{time.RFC3339, time.DateOnly}
+
+type timeValue struct {
+	p       *time.Time
+	layouts []string
+}
+
+func newTimeValue(val time.Time, p *time.Time, layouts []string) *timeValue {
+	if len(layouts) == 0 {
+		layouts = DefaultTimeLayouts
+	}
+
+	*p = val
+	return &timeValue{p: p, layouts: layouts}
+}
+
+// NewTimeValue returns a Value storing into p the first successful parse of
+// the given layouts, or of DefaultTimeLayouts if none is given.
+func NewTimeValue(p *time.Time, layouts ...string) Value {
+	return newTimeValue(*p, p, layouts)
+}
+
+func (t *timeValue) String() string {
+	if t.p.IsZero() {
+		return ""
+	}
+	return t.p.Format(t.layouts[0])
+}
+
+func (t *timeValue) Set(s string) error {
+	for _, layout := range t.layouts {
+		v, err := time.Parse(layout, s)
+		if err == nil {
+			*t.p = v
+			return nil
+		}
+	}
+	return fmt.Errorf("expected a time in format %s", strings.Join(t.layouts, " or "))
+}
+
+func (f *FlagSet) TimeVar(p *time.Time, value time.Time, layouts []string, shorthand, name, description string) {
+	f.Var(newTimeValue(value, p, layouts), shorthand, name, description)
+}
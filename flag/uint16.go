@@ -0,0 +1,25 @@
+package flag
+
+import "strconv"
+
+type uint16Value uint16
+
+func newUint16Value(val uint16, p *uint16) *uint16Value {
+	*p = val
+	return (*uint16Value)(p)
+}
+
+func (i *uint16Value) String() string { return strconv.FormatUint(uint64(*i), 10) }
+
+func (i *uint16Value) Set(s string) error {
+	v, err := strconv.ParseUint(s, 0, 16)
+	if err != nil {
+		err = numError(err)
+	}
+	*i = uint16Value(v)
+	return err
+}
+
+func (f *FlagSet) Uint16Var(p *uint16, value uint16, shorthand, name, description string) {
+	f.Var(newUint16Value(value, p), shorthand, name, description)
+}
@@ -0,0 +1,25 @@
+package flag
+
+import "strconv"
+
+type uint32Value uint32
+
+func newUint32Value(val uint32, p *uint32) *uint32Value {
+	*p = val
+	return (*uint32Value)(p)
+}
+
+func (i *uint32Value) String() string { return strconv.FormatUint(uint64(*i), 10) }
+
+func (i *uint32Value) Set(s string) error {
+	v, err := strconv.ParseUint(s, 0, 32)
+	if err != nil {
+		err = numError(err)
+	}
+	*i = uint32Value(v)
+	return err
+}
+
+func (f *FlagSet) Uint32Var(p *uint32, value uint32, shorthand, name, description string) {
+	f.Var(newUint32Value(value, p), shorthand, name, description)
+}
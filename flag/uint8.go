@@ -0,0 +1,25 @@
+package flag
+
+import "strconv"
+
+type uint8Value uint8
+
+func newUint8Value(val uint8, p *uint8) *uint8Value {
+	*p = val
+	return (*uint8Value)(p)
+}
+
+func (i *uint8Value) String() string { return strconv.FormatUint(uint64(*i), 10) }
+
+func (i *uint8Value) Set(s string) error {
+	v, err := strconv.ParseUint(s, 0, 8)
+	if err != nil {
+		err = numError(err)
+	}
+	*i = uint8Value(v)
+	return err
+}
+
+func (f *FlagSet) Uint8Var(p *uint8, value uint8, shorthand, name, description string) {
+	f.Var(newUint8Value(value, p), shorthand, name, description)
+}
@@ -0,0 +1,280 @@
+package structcli
+
+import (
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	helpIndent        = 2
+	helpColumnGap     = 2
+	helpMaxTermWidth  = 32
+	helpMinDescWidth  = 20
+	helpDefaultWidth  = 80
+	helpShortIndent   = "    "
+	helpSubcommandArg = "<COMMAND>"
+)
+
+type helpRow struct {
+	term        string
+	description string
+}
+
+// terminalWidth returns the width help is wrapped to, taken from $COLUMNS.
+func terminalWidth() int {
+	width, err := strconv.Atoi(os.Getenv("COLUMNS"))
+	if err != nil || width <= 0 {
+		return helpDefaultWidth
+	}
+	return width
+}
+
+func (c *commandSpec) writeHelp(w io.Writer, width int) error {
+	var b strings.Builder
+	c.writeUsages(&b)
+
+	if c.description != "" {
+		b.WriteString("\n")
+		for _, line := range wrapText(c.description, width) {
+			b.WriteString(line + "\n")
+		}
+	}
+
+	var arguments []helpRow
+	for _, pos := range c.positionals {
+		arguments = append(arguments, helpRow{pos.helpTerm(), pos.helpDescription()})
+	}
+	writeHelpSection(&b, "Arguments", arguments, width)
+
+	var options []helpRow
+	for _, opt := range c.options {
+		options = append(options, helpRow{opt.helpTerm(), opt.helpDescription()})
+	}
+	if term := c.helpOptionTerm(); term != "" {
+		options = append(options, helpRow{term, "Print help"})
+	}
+	if term := builtinOptionTerm(c.versionShort, c.versionLong, "-V", "--version"); term != "" {
+		options = append(options, helpRow{term, "Print version"})
+	}
+	writeHelpSection(&b, "Options", options, width)
+
+	var commands []helpRow
+	for _, sub := range c.subcommands {
+		commands = append(commands, helpRow{sub.name, sub.description})
+	}
+	writeHelpSection(&b, "Commands", commands, width)
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (c *commandSpec) writeUsages(b *strings.Builder) {
+	path := strings.Join(c.path(), " ")
+	for i, usage := range c.usages() {
+		if i == 0 {
+			b.WriteString("Usage: ")
+		} else {
+			b.WriteString("       ")
+		}
+		b.WriteString(path + usage + "\n")
+	}
+}
+
+// writeError prints err followed, for a usage error, by the usage lines of
+// the command it occurred in.
+func (c *commandSpec) writeError(w io.Writer, err error) error {
+	var b strings.Builder
+	b.WriteString("error: " + err.Error() + "\n")
+
+	if c != nil && isUsageError(err) {
+		b.WriteString("\n")
+		c.writeUsages(&b)
+
+		if term := c.helpOptionTerm(); term != "" {
+			names := strings.Fields(strings.ReplaceAll(term, ",", ""))
+			b.WriteString("\nFor more information, try '" + names[len(names)-1] + "'.\n")
+		}
+	}
+
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+func (c *commandSpec) usages() []string {
+	var positionals []string
+	for _, pos := range c.positionals {
+		positionals = append(positionals, pos.helpTerm())
+	}
+
+	usage := " [OPTIONS]"
+	if len(positionals) > 0 || len(c.subcommands) == 0 {
+		usages := []string{strings.TrimRight(usage+" "+strings.Join(positionals, " "), " ")}
+		if len(c.subcommands) > 0 {
+			usages = append(usages, usage+" "+helpSubcommandArg)
+		}
+		return usages
+	}
+	return []string{usage + " " + helpSubcommandArg}
+}
+
+// helpOptionTerm returns the names of the built-in help option that are not
+// taken by the command's own options.
+func (c *commandSpec) helpOptionTerm() string {
+	return builtinOptionTerm(c.optionsByName["-h"] == nil, c.optionsByName["--help"] == nil, "-h", "--help")
+}
+
+func builtinOptionTerm(hasShort, hasLong bool, short, long string) string {
+	switch {
+	case hasShort && hasLong:
+		return short + ", " + long
+	case hasShort:
+		return short
+	case hasLong:
+		return helpShortIndent + long
+	default:
+		return ""
+	}
+}
+
+func (o *optionSpec) helpTerm() string {
+	term := helpShortIndent
+	if o.shortName != "" {
+		term = o.shortName
+		if o.longName != "" {
+			term += ", "
+		}
+	}
+
+	names := o.getNames()
+	if o.shortName != "" {
+		names = names[1:]
+	}
+	term += strings.Join(names, ", ")
+
+	if !o.isBool {
+		term += " <" + o.placeholder + ">"
+		if o.repeatable {
+			term += "..."
+		}
+	}
+	return term
+}
+
+func (o *optionSpec) helpDescription() string {
+	var parts []string
+	if o.description != "" {
+		parts = append(parts, o.description)
+	}
+
+	if len(o.choices) > 0 {
+		parts = append(parts, "[possible values: "+strings.Join(o.choices, ", ")+"]")
+	}
+
+	if o.defaultValue != "" {
+		parts = append(parts, "[default: "+o.defaultValue+"]")
+	}
+
+	if o.envName != "" {
+		parts = append(parts, "[env: "+o.envName+"]")
+	}
+
+	if o.required {
+		parts = append(parts, "[required]")
+	}
+	return strings.Join(parts, " ")
+}
+
+func (p *positionalSpec) helpDescription() string {
+	var parts []string
+	if p.description != "" {
+		parts = append(parts, p.description)
+	}
+
+	if len(p.choices) > 0 {
+		parts = append(parts, "[possible values: "+strings.Join(p.choices, ", ")+"]")
+	}
+
+	if p.defaultValue != "" {
+		parts = append(parts, "[default: "+p.defaultValue+"]")
+	}
+	return strings.Join(parts, " ")
+}
+
+func (p *positionalSpec) helpTerm() string {
+	switch {
+	case p.isSlice && p.required:
+		return "<" + p.placeholder + ">..."
+	case p.isSlice:
+		return "[" + p.placeholder + "]..."
+	case p.required:
+		return "<" + p.placeholder + ">"
+	default:
+		return "[" + p.placeholder + "]"
+	}
+}
+
+func writeHelpSection(b *strings.Builder, title string, rows []helpRow, width int) {
+	if len(rows) == 0 {
+		return
+	}
+
+	termWidth := 0
+	for _, row := range rows {
+		if n := utf8.RuneCountInString(row.term); n <= helpMaxTermWidth {
+			termWidth = max(termWidth, n)
+		}
+	}
+
+	descIndent := helpIndent + termWidth + helpColumnGap
+	descWidth := max(width-descIndent, helpMinDescWidth)
+
+	b.WriteString("\n" + title + ":\n")
+	for _, row := range rows {
+		b.WriteString(strings.Repeat(" ", helpIndent) + row.term)
+
+		lines := wrapText(row.description, descWidth)
+		if len(lines) == 0 {
+			b.WriteString("\n")
+			continue
+		}
+
+		// Terms too long for the column get their description on the next line.
+		n := utf8.RuneCountInString(row.term)
+		if n > termWidth {
+			b.WriteString("\n" + strings.Repeat(" ", descIndent))
+		} else {
+			b.WriteString(strings.Repeat(" ", descIndent-helpIndent-n))
+		}
+
+		b.WriteString(lines[0] + "\n")
+		for _, line := range lines[1:] {
+			b.WriteString(strings.Repeat(" ", descIndent) + line + "\n")
+		}
+	}
+}
+
+// wrapText splits text into lines of at most width runes, breaking at spaces.
+func wrapText(text string, width int) []string {
+	var lines []string
+	var line string
+
+	for _, word := range strings.Fields(text) {
+		if line != "" && utf8.RuneCountInString(line)+1+utf8.RuneCountInString(word) > width {
+			lines = append(lines, line)
+			line = ""
+		}
+
+		if line != "" {
+			line += " "
+		}
+		line += word
+	}
+
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
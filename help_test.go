@@ -0,0 +1,129 @@
+package structcli_test
+
+import (
+	"errors"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/tsingmuhe/structcli"
+)
+
+type HelpCommand struct {
+	Verbose bool              `short:"-v" long:"--verbose,negatable" description:"Print more output while running"`
+	Config  *string           `short:"-c" long:"--config" placeholder:"FILE" description:"Config file"`
+	Name    string            `long:"--name" placeholder:"NAME" env:"NAME" description:"Name" required:"true"`
+	Tags    []string          `long:"--tag" placeholder:"TAG" description:"Tags"`
+	Labels  map[string]string `short:"-l" long:"--label" description:"Labels"`
+
+	Target string   `placeholder:"TARGET" description:"Deploy target" required:"true"`
+	Extra  []string `placeholder:"ARG" description:"Extra arguments"`
+
+	Sub *HelpSubCommand `command:"sub" description:"Sub command"`
+}
+
+type HelpSubCommand struct {
+	Help bool `short:"-H" long:"--help" description:"Custom help"`
+}
+
+func TestCommandLine_Help(t *testing.T) {
+	t.Setenv("COLUMNS", "60")
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{
+			args: []string{"--verbose", "-h"},
+			want: `Usage: cmd [OPTIONS] <TARGET> [ARG]...
+       cmd [OPTIONS] <COMMAND>
+
+a test cmd
+
+Arguments:
+  <TARGET>  Deploy target
+  [ARG]...  Extra arguments
+
+Options:
+  -v, --verbose, --no-verbose  Print more output while
+                               running
+  -c, --config <FILE>          Config file
+      --name <NAME>            Name [env: NAME] [required]
+      --tag <TAG>...           Tags [default: a,b]
+  -l, --label <KEY=VALUE>...   Labels
+  -h, --help                   Print help
+      --version                Print version
+
+Commands:
+  sub  Sub command
+`,
+		},
+		{
+			args: []string{"sub", "-h"},
+			want: `Usage: cmd sub [OPTIONS]
+
+Sub command
+
+Options:
+  -H, --help  Custom help
+  -h          Print help
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		var b strings.Builder
+		cli := structcli.Create("cmd", "a test cmd", "1.0.0", &HelpCommand{Tags: []string{"a", "b"}})
+		cli.SetOutput(&b)
+
+		_, err := cli.Parse(tt.args)
+		out := b.String()
+
+		if !errors.Is(err, structcli.ErrHelp) {
+			t.Fatalf("expected ErrHelp for %q, got %v", tt.args, err)
+		}
+
+		if out != tt.want {
+			t.Errorf("unexpected help for %q:\n%s\nwant:\n%s", tt.args, out, tt.want)
+		}
+	}
+}
+
+func TestCommandLine_Version(t *testing.T) {
+	tests := []struct {
+		args      []string
+		shorthand bool
+		template  string
+		want      string
+	}{
+		{args: []string{"--version"}, want: "cmd 1.0.0\n"},
+		{args: []string{"prod", "--version"}, want: "cmd 1.0.0\n"},
+		{args: []string{"-V"}, shorthand: true, template: "{{.Name}} version {{.Version}} {{.GoVersion}}\n", want: "cmd version 1.0.0 " + runtime.Version() + "\n"},
+	}
+
+	for _, tt := range tests {
+		var b strings.Builder
+		cli := structcli.Create("cmd", "a test cmd", "1.0.0", new(HelpCommand))
+		cli.SetOutput(&b)
+		cli.SetVersionShorthand(tt.shorthand)
+		if tt.template != "" {
+			cli.SetVersionTemplate(tt.template)
+		}
+
+		_, err := cli.Parse(tt.args)
+		out := b.String()
+
+		if !errors.Is(err, structcli.ErrVersion) {
+			t.Fatalf("expected ErrVersion for %q, got %v", tt.args, err)
+		}
+
+		if out != tt.want {
+			t.Errorf("unexpected version for %q: %q, want %q", tt.args, out, tt.want)
+		}
+	}
+
+	_, err := structcli.Create("cmd", "a test cmd", "1.0.0", new(HelpCommand)).Parse([]string{"-V"})
+	if err == nil || errors.Is(err, structcli.ErrVersion) {
+		t.Fatalf("-V must not be defined unless enabled, got %v", err)
+	}
+}
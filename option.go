@@ -2,8 +2,11 @@ package structcli
 
 import (
 	"fmt"
+	"os"
 	"reflect"
 	"strings"
+
+	"github.com/tsingmuhe/structcli/flag"
 )
 
 type optionSpec struct {
@@ -12,13 +15,17 @@ type optionSpec struct {
 
 	placeholder string
 	description string
-	required    bool
+	envName     string
+	configKey   string
 
-	isBool    bool
-	negatable bool
+	isBool     bool
+	negatable  bool
+	repeatable bool
+	isMap      bool
 
-	values  []string
-	changed bool
+	fieldSpec
+	flag     *flag.Flag
+	negation *flag.Flag
 }
 
 func (o *optionSpec) getNames() []string {
@@ -40,34 +47,168 @@ func (o *optionSpec) getNames() []string {
 	return names
 }
 
-func newOptionSpec(sf *structField) (*optionSpec, error) {
+func (o *optionSpec) getName() string {
+	if len(o.longName) > 0 {
+		return o.longName
+	}
+	return o.shortName
+}
+
+func (o *optionSpec) changed() bool {
+	return o.flag.Changed || o.negation != nil && o.negation.Changed
+}
+
+// resolve completes the option when it is missing from the command line,
+// from its environment variable, the configuration file or its default in
+// that order, and checks that a required option is set.
+func (o *optionSpec) resolve(path []string, config *Config) error {
+	if o.origin.Source != SourceUnset {
+		return nil
+	}
+
+	if o.changed() {
+		o.origin = Origin{Source: SourceArgs}
+		return nil
+	}
+
+	for _, apply := range []func([]string, *Config) (Origin, error){o.applyEnv, o.applyConfig, o.applyDefaultValue} {
+		origin, err := apply(path, config)
+		if err != nil {
+			return err
+		}
+
+		if origin.Source != SourceUnset {
+			o.origin = origin
+			return nil
+		}
+	}
+
+	if o.required {
+		return &MissingRequiredError{Command: path, Name: o.getName()}
+	}
+	return nil
+}
+
+func (o *optionSpec) applyEnv(path []string, _ *Config) (Origin, error) {
+	if o.envName == "" {
+		return Origin{}, nil
+	}
+
+	value, ok := os.LookupEnv(o.envName)
+	if !ok {
+		return Origin{}, nil
+	}
+
+	origin := Origin{Source: SourceEnv, Env: o.envName}
+	err := o.value.Set(value)
+	if err != nil {
+		return Origin{}, &InvalidValueError{Command: path, Name: o.getName(), Value: value, Origin: origin, Err: err}
+	}
+	return origin, nil
+}
+
+func (o *optionSpec) applyConfig(path []string, config *Config) (Origin, error) {
+	if o.configKey == "" {
+		return Origin{}, nil
+	}
+
+	entries := config.lookup(o.configKey, o.isMap)
+	for _, entry := range entries {
+		err := o.value.Set(entry.value)
+		if err != nil {
+			origin := Origin{Source: SourceConfig, File: config.path, Line: entry.line}
+			return Origin{}, &InvalidValueError{Command: path, Name: o.getName(), Value: entry.value, Origin: origin, Err: err}
+		}
+	}
+
+	if len(entries) == 0 {
+		return Origin{}, nil
+	}
+	return Origin{Source: SourceConfig, File: config.path, Line: entries[0].line}, nil
+}
+
+// applyDefaultValue adapts applyDefault to the sources tried by resolve.
+func (o *optionSpec) applyDefaultValue([]string, *Config) (Origin, error) {
+	return o.applyDefault()
+}
+
+func (o *optionSpec) register(flags *flag.FlagSet) {
+	shorthand := strings.TrimPrefix(o.shortName, "-")
+	name := strings.TrimPrefix(o.longName, "--")
+
+	flags.Var(o.value, shorthand, name, o.description)
+	if name != "" {
+		o.flag = flags.Lookup(name)
+	} else {
+		o.flag = flags.ShorthandLookup(shorthand)
+	}
+
+	if o.negatable {
+		flags.Var(negatedValue{o.value}, "", "no-"+name, o.description)
+		o.negation = flags.Lookup("no-" + name)
+	}
+}
+
+func newOptionSpec(sf *structField, v reflect.Value) (*optionSpec, error) {
 	shortName := sf.getShortName()
 	longName, negatable := sf.getLongName()
 
+	if len(shortName) > 0 && (len(shortName) != 2 || shortName[0] != '-' || shortName[1] == '-' || shortName[1] == '=') {
+		return nil, fmt.Errorf("short name '%s' for field `%s` must be a single character prefixed with '-'", shortName, sf.Name)
+	}
+
+	if len(longName) > 0 && (len(longName) < 3 || !strings.HasPrefix(longName, "--") || longName[2] == '-' || strings.Contains(longName, "=")) {
+		return nil, fmt.Errorf("long name '%s' for field `%s` must be prefixed with '--'", longName, sf.Name)
+	}
+
+	if negatable && len(longName) == 0 {
+		return nil, fmt.Errorf("option field `%s` is negatable but has no long name", sf.Name)
+	}
+
 	t, ptr := sf.indirectType()
+	if sf.getLayout() != "" && sf.elemType() != timeType {
+		return nil, fmt.Errorf("option field `%s` has a layout but is not a time", sf.Name)
+	}
+
+	repeatable := isSliceType(t) || isMapType(t)
+	if ptr && repeatable || !isScalarType(t) && !repeatable {
+		return nil, fmt.Errorf("option field `%s` type is invalid: %s", sf.Name, sf.Type.String())
+	}
+
+	if sf.getSeparator() != "" && !repeatable {
+		return nil, fmt.Errorf("option field `%s` has a separator but is not a slice or a map", sf.Name)
+	}
 
-	switch t.Kind() {
-	case reflect.Bool:
-		return &optionSpec{
-			shortName:   shortName,
-			longName:    longName,
-			placeholder: "",
-			description: sf.getDescription(),
-			required:    !ptr,
-			isBool:      true,
-			negatable:   negatable,
-		}, nil
-	case reflect.String:
-		return &optionSpec{
-			shortName:   shortName,
-			longName:    longName,
-			placeholder: sf.getPlaceholder(),
-			description: sf.getDescription(),
-			required:    !ptr,
-			isBool:      false,
-			negatable:   false,
-		}, nil
-	default:
-		return nil, fmt.Errorf("option field `%s` type is invalid: %s", sf.Name, t.String())
+	if duplicate := sf.getDuplicate(); duplicate != "" && (!isMapType(t) || duplicate != "error" && duplicate != "last") {
+		return nil, fmt.Errorf("option field `%s` has an invalid duplicate policy '%s'", sf.Name, duplicate)
 	}
+
+	field, err := newFieldSpec(sf, v, "option")
+	if err != nil {
+		return nil, err
+	}
+
+	isBool := isBoolValue(field.value)
+	if negatable && !isBool {
+		return nil, fmt.Errorf("option field `%s` is negatable but not a boolean", sf.Name)
+	}
+
+	placeholder := ""
+	if !isBool {
+		placeholder = sf.getPlaceholder()
+	}
+
+	return &optionSpec{
+		shortName:   shortName,
+		longName:    longName,
+		placeholder: placeholder,
+		description: sf.getDescription(),
+		envName:     sf.getEnv(),
+		configKey:   sf.getConfigKey(longName),
+		isBool:      isBool,
+		negatable:   negatable,
+		repeatable:  repeatable,
+		isMap:       isMapType(t),
+		fieldSpec:   field,
+	}, nil
 }
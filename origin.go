@@ -0,0 +1,126 @@
+package structcli
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Source is the kind of place the value of an option or a positional came
+// from.
+type Source int
+
+const (
+	SourceUnset Source = iota
+	SourceArgs
+	SourceEnv
+	SourceConfig
+	SourceDefault
+)
+
+func (s Source) String() string {
+	switch s {
+	case SourceArgs:
+		return "command line"
+	case SourceEnv:
+		return "environment"
+	case SourceConfig:
+		return "config file"
+	case SourceDefault:
+		return "default"
+	default:
+		return "unset"
+	}
+}
+
+// Origin tells where the value of an option or a positional came from.
+type Origin struct {
+	Source Source
+
+	// Env is the environment variable the value was read from.
+	Env string
+
+	// File and Line locate the value in the configuration file.
+	File string
+	Line int
+}
+
+func (o Origin) String() string {
+	switch o.Source {
+	case SourceEnv:
+		return "environment variable " + o.Env
+	case SourceConfig:
+		return fmt.Sprintf("config file %s:%d", o.File, o.Line)
+	default:
+		return o.Source.String()
+	}
+}
+
+// Setting is the effective value of an option or a positional of a selected
+// command.
+type Setting struct {
+	// Command is the path of the command defining the setting.
+	Command []string
+
+	// Name is the option name, e.g. "--port", or the positional placeholder,
+	// e.g. "<TARGET>".
+	Name string
+
+	Value  string
+	Origin Origin
+}
+
+func (c *commandSpec) settings() []Setting {
+	var settings []Setting
+
+	for _, cmd := range c.chain() {
+		path := cmd.path()
+
+		for _, opt := range cmd.options {
+			settings = append(settings, Setting{
+				Command: path,
+				Name:    opt.getName(),
+				Value:   opt.value.String(),
+				Origin:  opt.origin,
+			})
+		}
+
+		for _, pos := range cmd.positionals {
+			settings = append(settings, Setting{
+				Command: path,
+				Name:    pos.helpTerm(),
+				Value:   pos.value.String(),
+				Origin:  pos.origin,
+			})
+		}
+	}
+	return settings
+}
+
+// writeSettings dumps settings as `name = value  # origin` lines grouped by
+// command.
+func writeSettings(w io.Writer, settings []Setting) error {
+	var b strings.Builder
+
+	var command string
+	nameWidth, valueWidth := 0, 0
+	for _, s := range settings {
+		nameWidth = max(nameWidth, len(s.Name))
+		valueWidth = max(valueWidth, len(s.Value))
+	}
+
+	for _, s := range settings {
+		if path := strings.Join(s.Command, " "); path != command {
+			if command != "" {
+				b.WriteString("\n")
+			}
+			command = path
+			b.WriteString("[" + command + "]\n")
+		}
+
+		fmt.Fprintf(&b, "%-*s = %-*s  # %s\n", nameWidth, s.Name, valueWidth, s.Value, s.Origin)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
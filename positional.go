@@ -9,27 +9,44 @@ type positionalSpec struct {
 	description string
 
 	placeholder string
-	required    bool
+	isSlice     bool
 
-	values  []string
-	changed bool
+	fieldSpec
 }
 
-func newPositionalSpec(sf *structField) (*positionalSpec, error) {
+func (p *positionalSpec) set(path []string, values []string) error {
+	for _, value := range values {
+		err := p.value.Set(value)
+		if err != nil {
+			return &InvalidValueError{Command: path, Name: p.placeholder, Value: value, Origin: Origin{Source: SourceArgs}, Err: err}
+		}
+	}
+
+	p.origin = Origin{Source: SourceArgs}
+	return nil
+}
+
+func newPositionalSpec(sf *structField, v reflect.Value) (*positionalSpec, error) {
 	t, ptr := sf.indirectType()
 
-	switch {
-	case t.Kind() == reflect.String:
-		return &positionalSpec{}, nil
-	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.String:
-		return &positionalSpec{
-			description: "",
-			placeholder: "",
-			required:    false,
-			values:      nil,
-			changed:     false,
-		}, nil
-	default:
-		return nil, fmt.Errorf("option field `%s` type is invalid: %s", sf.Name, t.String())
+	if sf.getLayout() != "" && sf.elemType() != timeType {
+		return nil, fmt.Errorf("positional field `%s` has a layout but is not a time", sf.Name)
 	}
+
+	isSlice := isSliceType(t) && !ptr
+	if !isSlice && !isScalarType(t) {
+		return nil, fmt.Errorf("positional field `%s` type is invalid: %s", sf.Name, t.String())
+	}
+
+	field, err := newFieldSpec(sf, v, "positional")
+	if err != nil {
+		return nil, err
+	}
+
+	return &positionalSpec{
+		description: sf.getDescription(),
+		placeholder: sf.getPlaceholder(),
+		isSlice:     isSlice,
+		fieldSpec:   field,
+	}, nil
 }
@@ -0,0 +1,30 @@
+package structcli
+
+import (
+	"io"
+	"strings"
+)
+
+type Result[T any] struct {
+	// Command is the root command struct with the selected subcommands allocated.
+	Command *T
+
+	// Path holds the names of the selected commands, starting with the root,
+	// e.g. []string{"cmd", "sub"}.
+	Path []string
+
+	// Settings holds the effective value of every option and positional of
+	// the selected commands, along with its origin.
+	Settings []Setting
+}
+
+// Selected returns the selected command path joined by spaces, e.g. "cmd sub".
+func (r *Result[T]) Selected() string {
+	return strings.Join(r.Path, " ")
+}
+
+// WriteSettings dumps the effective settings with their origin, e.g. to
+// implement a --print-config option.
+func (r *Result[T]) WriteSettings(w io.Writer) error {
+	return writeSettings(w, r.Settings)
+}
@@ -0,0 +1,41 @@
+package structcli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Runner is implemented by command structs that can be executed by
+// CommandLine.Execute.
+type Runner interface {
+	Run(ctx context.Context) error
+}
+
+type parentsKey struct{}
+
+// Parent returns the nearest selected ancestor command of type *P from the
+// context passed to Runner.Run.
+func Parent[P any](ctx context.Context) (*P, bool) {
+	parents, _ := ctx.Value(parentsKey{}).([]any)
+	for i := len(parents) - 1; i >= 0; i-- {
+		if p, ok := parents[i].(*P); ok {
+			return p, true
+		}
+	}
+	return nil, false
+}
+
+func (c *commandSpec) run(ctx context.Context) error {
+	runner, ok := c.value.Addr().Interface().(Runner)
+	if !ok {
+		return &SpecError{Command: c.path(), Err: fmt.Errorf("command `%s` cannot be run", strings.Join(c.path(), " "))}
+	}
+
+	var parents []any
+	for p := c.parent; p != nil; p = p.parent {
+		parents = append([]any{p.value.Addr().Interface()}, parents...)
+	}
+
+	return runner.Run(context.WithValue(ctx, parentsKey{}, parents))
+}
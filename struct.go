@@ -1,8 +1,12 @@
 package structcli
 
 import (
+	"fmt"
 	"reflect"
+	"strconv"
 	"strings"
+
+	"github.com/tsingmuhe/structcli/flag"
 )
 
 type structField reflect.StructField
@@ -56,18 +60,112 @@ func (s *structField) getLongName() (string, bool) {
 	return tagParts[0], negatable
 }
 
+// elemType returns the type of the values stored in the field, looking
+// through pointers, slices and maps.
+func (s *structField) elemType() reflect.Type {
+	t, _ := s.indirectType()
+	if isSliceType(t) || isMapType(t) {
+		return t.Elem()
+	}
+	return t
+}
+
 func (s *structField) getPlaceholder() string {
 	placeholder := s.Tag.Get("placeholder")
-	if placeholder == "" {
-		placeholder = s.Name
+	if placeholder != "" {
+		return placeholder
+	}
+
+	if t, _ := s.indirectType(); isMapType(t) {
+		return "KEY=VALUE"
+	}
+
+	switch s.elemType() {
+	case durationType:
+		return "DURATION"
+	case timeType:
+		if layout := s.getLayout(); layout != "" {
+			return layout
+		}
+		return strings.Join(flag.DefaultTimeLayouts, "|")
+	default:
+		return s.Name
 	}
-	return placeholder
 }
 
 func (s *structField) getDescription() string {
 	return s.Tag.Get("description")
 }
 
+func (s *structField) getSeparator() string {
+	return s.Tag.Get("separator")
+}
+
+func (s *structField) getDefault() (string, bool) {
+	return s.Tag.Lookup("default")
+}
+
+func (s *structField) isRequired() (bool, error) {
+	tag, ok := s.Tag.Lookup("required")
+	if !ok {
+		return false, nil
+	}
+
+	if tag == "" {
+		return true, nil
+	}
+
+	required, err := strconv.ParseBool(tag)
+	if err != nil {
+		return false, fmt.Errorf("field `%s` has an invalid required tag '%s'", s.Name, tag)
+	}
+	return required, nil
+}
+
+func (s *structField) getEnv() string {
+	return s.Tag.Get("env")
+}
+
+// getConfigKey returns the key of the option in configuration files, taken
+// from the config tag or the long name. A "-" tag excludes the option.
+func (s *structField) getConfigKey(longName string) string {
+	key := s.Tag.Get("config")
+	switch key {
+	case "-":
+		return ""
+	case "":
+		return strings.TrimPrefix(longName, "--")
+	default:
+		return key
+	}
+}
+
+func (s *structField) getLayout() string {
+	return s.Tag.Get("layout")
+}
+
+func (s *structField) getDuplicate() string {
+	return s.Tag.Get("duplicate")
+}
+
+func (s *structField) getChoices() []string {
+	tag := s.Tag.Get("choices")
+	if tag == "" {
+		return nil
+	}
+
+	choices := strings.Split(tag, ",")
+	for i, choice := range choices {
+		choices[i] = strings.TrimSpace(choice)
+	}
+	return choices
+}
+
+// getRule returns the validation tag name of the field.
+func (s *structField) getRule(name string) (string, bool) {
+	return s.Tag.Lookup(name)
+}
+
 type scanHandler func(*structField, reflect.Value) error
 
 func scanStruct(t reflect.Type, v reflect.Value, handler scanHandler) error {
@@ -0,0 +1,170 @@
+package structcli
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// A Suggestion is a defined option or subcommand whose name is close to a
+// mistyped one. Command is the path of the command defining it, which differs
+// from the command of the error for an option given at the wrong level.
+type Suggestion struct {
+	Name    string
+	Command []string
+}
+
+// formatSuggestions renders suggestions as a "did you mean" clause, naming
+// the defining command of those that do not belong to command.
+func formatSuggestions(command []string, suggestions []Suggestion) string {
+	if len(suggestions) == 0 {
+		return ""
+	}
+
+	names := make([]string, len(suggestions))
+	for i, s := range suggestions {
+		names[i] = "`" + s.Name + "`"
+		if !slices.Equal(s.Command, command) {
+			names[i] += fmt.Sprintf(" of command `%s`", strings.Join(s.Command, " "))
+		}
+	}
+	return ", did you mean " + strings.Join(names, " or ") + "?"
+}
+
+// suggestOptions returns the options of c close to name, followed by those of
+// its parents and descendants, the closest first.
+func (c *commandSpec) suggestOptions(name string) []Suggestion {
+	type candidate struct {
+		Suggestion
+		distance int
+		level    int
+	}
+
+	var candidates []candidate
+	add := func(cmd *commandSpec, level int, names ...string) {
+		for _, n := range names {
+			d := editDistance(name, n)
+			if d > maxEditDistance(name) {
+				continue
+			}
+			candidates = append(candidates, candidate{Suggestion{n, cmd.path()}, d, level})
+		}
+	}
+
+	add(c, 0, c.builtinNames()...)
+	for n := range c.optionsByName {
+		add(c, 0, n)
+	}
+
+	for parent := c.parent; parent != nil; parent = parent.parent {
+		for n := range parent.optionsByName {
+			add(parent, 1, n)
+		}
+	}
+
+	var walk func(cmd *commandSpec)
+	walk = func(cmd *commandSpec) {
+		for _, sub := range cmd.subcommands {
+			for n := range sub.optionsByName {
+				add(sub, 1, n)
+			}
+			walk(sub)
+		}
+	}
+	walk(c)
+
+	slices.SortStableFunc(candidates, func(a, b candidate) int {
+		if a.distance != b.distance {
+			return a.distance - b.distance
+		}
+		if a.level != b.level {
+			return a.level - b.level
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	// A name defined at several levels is only suggested once, from the
+	// nearest command.
+	var suggestions []Suggestion
+	seen := make(map[string]bool)
+	for _, cand := range candidates {
+		if !seen[cand.Name] {
+			seen[cand.Name] = true
+			suggestions = append(suggestions, cand.Suggestion)
+		}
+	}
+	return suggestions
+}
+
+// builtinNames returns the names of the options handled by the library that
+// the user did not define.
+func (c *commandSpec) builtinNames() []string {
+	var names []string
+	if c.optionsByName["-h"] == nil {
+		names = append(names, "-h")
+	}
+	if c.optionsByName["--help"] == nil {
+		names = append(names, "--help")
+	}
+	if c.versionShort {
+		names = append(names, "-V")
+	}
+	if c.versionLong {
+		names = append(names, "--version")
+	}
+	return names
+}
+
+// suggestCommands returns the subcommands of c close to name, the closest
+// first.
+func (c *commandSpec) suggestCommands(name string) []Suggestion {
+	var suggestions []Suggestion
+	for _, sub := range c.subcommands {
+		if editDistance(name, sub.name) <= maxEditDistance(name) {
+			suggestions = append(suggestions, Suggestion{sub.name, c.path()})
+		}
+	}
+
+	slices.SortStableFunc(suggestions, func(a, b Suggestion) int {
+		return editDistance(name, a.Name) - editDistance(name, b.Name)
+	})
+	return suggestions
+}
+
+// maxEditDistance is the largest distance at which a name is still
+// considered a typo of name. Short options are only matched exactly.
+func maxEditDistance(name string) int {
+	if len(name) == 2 && name[0] == '-' {
+		return 0
+	}
+	return max(1, len(strings.TrimLeft(name, "-"))/3)
+}
+
+// editDistance returns the optimal string alignment distance between a and
+// b, i.e. the Levenshtein distance where swapping two adjacent characters
+// counts as a single edit.
+func editDistance(a, b string) int {
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(a)][len(b)]
+}
@@ -0,0 +1,285 @@
+package structcli
+
+import (
+	"cmp"
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/tsingmuhe/structcli/flag"
+)
+
+// A rule checks a value of a field, or the field itself for mincount and
+// maxcount, against one of its validation tags.
+type rule struct {
+	count bool
+	check func(v reflect.Value) error
+}
+
+// validator checks the values of a field against its validation tags once
+// all sources have been merged: min and max for numbers and durations,
+// pattern, minlen and maxlen for strings, applied to each element of slices
+// and maps, and mincount and maxcount for the number of elements of slices
+// and maps.
+type validator struct {
+	field reflect.Value
+	rules []rule
+}
+
+type violation struct {
+	value string
+	err   error
+}
+
+func newValidator(sf *structField, v reflect.Value) (*validator, error) {
+	t, _ := sf.indirectType()
+	elem := sf.elemType()
+	var rules []rule
+
+	for _, name := range []string{"min", "max"} {
+		tag, ok := sf.getRule(name)
+		if !ok {
+			continue
+		}
+
+		if !isNumberKind(elem.Kind()) {
+			return nil, fmt.Errorf("field `%s` has a %s tag but is not a number", sf.Name, name)
+		}
+
+		compare, err := parseLimit(elem, tag)
+		if err != nil {
+			return nil, fmt.Errorf("field `%s` has an invalid %s tag '%s'", sf.Name, name, tag)
+		}
+
+		if name == "min" {
+			rules = append(rules, rule{check: func(v reflect.Value) error {
+				if compare(v) < 0 {
+					return fmt.Errorf("must be at least %s", tag)
+				}
+				return nil
+			}})
+		} else {
+			rules = append(rules, rule{check: func(v reflect.Value) error {
+				if compare(v) > 0 {
+					return fmt.Errorf("must be at most %s", tag)
+				}
+				return nil
+			}})
+		}
+	}
+
+	if tag, ok := sf.getRule("pattern"); ok {
+		if elem.Kind() != reflect.String {
+			return nil, fmt.Errorf("field `%s` has a pattern tag but is not a string", sf.Name)
+		}
+
+		// The pattern must match the whole value.
+		re, err := regexp.Compile("^(?:" + tag + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("field `%s` has an invalid pattern tag: %w", sf.Name, err)
+		}
+
+		rules = append(rules, rule{check: func(v reflect.Value) error {
+			if !re.MatchString(v.String()) {
+				return fmt.Errorf("must match the pattern %s", tag)
+			}
+			return nil
+		}})
+	}
+
+	for _, name := range []string{"minlen", "maxlen"} {
+		tag, ok := sf.getRule(name)
+		if !ok {
+			continue
+		}
+
+		if elem.Kind() != reflect.String {
+			return nil, fmt.Errorf("field `%s` has a %s tag but is not a string", sf.Name, name)
+		}
+
+		limit, err := strconv.Atoi(tag)
+		if err != nil || limit < 0 {
+			return nil, fmt.Errorf("field `%s` has an invalid %s tag '%s'", sf.Name, name, tag)
+		}
+
+		if name == "minlen" {
+			rules = append(rules, rule{check: func(v reflect.Value) error {
+				if utf8.RuneCountInString(v.String()) < limit {
+					return fmt.Errorf("must be at least %s long", pluralize(limit, "character"))
+				}
+				return nil
+			}})
+		} else {
+			rules = append(rules, rule{check: func(v reflect.Value) error {
+				if utf8.RuneCountInString(v.String()) > limit {
+					return fmt.Errorf("must be at most %s long", pluralize(limit, "character"))
+				}
+				return nil
+			}})
+		}
+	}
+
+	for _, name := range []string{"mincount", "maxcount"} {
+		tag, ok := sf.getRule(name)
+		if !ok {
+			continue
+		}
+
+		if !isSliceType(t) && !isMapType(t) {
+			return nil, fmt.Errorf("field `%s` has a %s tag but is not a slice or a map", sf.Name, name)
+		}
+
+		limit, err := strconv.Atoi(tag)
+		if err != nil || limit < 0 {
+			return nil, fmt.Errorf("field `%s` has an invalid %s tag '%s'", sf.Name, name, tag)
+		}
+
+		if name == "mincount" {
+			rules = append(rules, rule{count: true, check: func(v reflect.Value) error {
+				if v.Len() < limit {
+					return fmt.Errorf("must have at least %s", pluralize(limit, "value"))
+				}
+				return nil
+			}})
+		} else {
+			rules = append(rules, rule{count: true, check: func(v reflect.Value) error {
+				if v.Len() > limit {
+					return fmt.Errorf("must have at most %s", pluralize(limit, "value"))
+				}
+				return nil
+			}})
+		}
+	}
+
+	if len(rules) == 0 {
+		return nil, nil
+	}
+	return &validator{field: v, rules: rules}, nil
+}
+
+// validate returns every violation of the rules by the field, whose value is
+// also available as value. Only mincount and maxcount apply to a field that
+// has not been set.
+func (val *validator) validate(value flag.Value, set bool) []violation {
+	v := val.field
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v = reflect.Zero(v.Type().Elem())
+		} else {
+			v = v.Elem()
+		}
+	}
+
+	var elems []reflect.Value
+	switch {
+	case isSliceType(v.Type()):
+		for i := 0; i < v.Len(); i++ {
+			elems = append(elems, v.Index(i))
+		}
+	case isMapType(v.Type()):
+		keys := v.MapKeys()
+		slices.SortFunc(keys, func(a, b reflect.Value) int {
+			return strings.Compare(a.String(), b.String())
+		})
+		for _, key := range keys {
+			elems = append(elems, v.MapIndex(key))
+		}
+	default:
+		elems = []reflect.Value{v}
+	}
+
+	var violations []violation
+	for _, r := range val.rules {
+		if r.count {
+			if err := r.check(v); err != nil {
+				violations = append(violations, violation{value.String(), err})
+			}
+			continue
+		}
+
+		if !set {
+			continue
+		}
+
+		for _, elem := range elems {
+			if err := r.check(elem); err != nil {
+				violations = append(violations, violation{fmt.Sprint(elem.Interface()), err})
+			}
+		}
+	}
+	return violations
+}
+
+// validate checks the options and positionals of c and returns an error per
+// violation.
+func (c *commandSpec) validate() []*InvalidValueError {
+	var errs []*InvalidValueError
+	for _, opt := range c.options {
+		if opt.validator == nil {
+			continue
+		}
+
+		for _, v := range opt.validator.validate(opt.value, opt.origin.Source != SourceUnset) {
+			errs = append(errs, &InvalidValueError{Command: c.path(), Name: opt.getName(), Value: v.value, Origin: opt.origin, Err: v.err})
+		}
+	}
+
+	for _, pos := range c.positionals {
+		if pos.validator == nil {
+			continue
+		}
+
+		for _, v := range pos.validator.validate(pos.value, pos.origin.Source != SourceUnset) {
+			errs = append(errs, &InvalidValueError{Command: c.path(), Name: pos.placeholder, Value: v.value, Origin: pos.origin, Err: v.err})
+		}
+	}
+	return errs
+}
+
+func isNumberKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	default:
+		return false
+	}
+}
+
+// parseLimit parses the limit of a min or max tag, given as a duration for
+// time.Duration fields, and returns a function comparing a value with it.
+// Integers are compared exactly and floats at the precision of the field.
+func parseLimit(t reflect.Type, s string) (func(v reflect.Value) int, error) {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var limit int64
+		var err error
+		if t == durationType {
+			var d time.Duration
+			d, err = time.ParseDuration(s)
+			limit = int64(d)
+		} else {
+			limit, err = strconv.ParseInt(s, 10, 64)
+		}
+		return func(v reflect.Value) int { return cmp.Compare(v.Int(), limit) }, err
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		limit, err := strconv.ParseUint(s, 10, 64)
+		return func(v reflect.Value) int { return cmp.Compare(v.Uint(), limit) }, err
+	default:
+		limit, err := strconv.ParseFloat(s, t.Bits())
+		return func(v reflect.Value) int { return cmp.Compare(v.Float(), limit) }, err
+	}
+}
+
+func pluralize(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return strconv.Itoa(n) + " " + noun + "s"
+}
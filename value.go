@@ -0,0 +1,354 @@
+package structcli
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tsingmuhe/structcli/flag"
+)
+
+var kindTypes = map[reflect.Kind]reflect.Type{
+	reflect.Bool:    reflect.TypeFor[bool](),
+	reflect.String:  reflect.TypeFor[string](),
+	reflect.Int:     reflect.TypeFor[int](),
+	reflect.Int8:    reflect.TypeFor[int8](),
+	reflect.Int16:   reflect.TypeFor[int16](),
+	reflect.Int32:   reflect.TypeFor[int32](),
+	reflect.Int64:   reflect.TypeFor[int64](),
+	reflect.Uint:    reflect.TypeFor[uint](),
+	reflect.Uint8:   reflect.TypeFor[uint8](),
+	reflect.Uint16:  reflect.TypeFor[uint16](),
+	reflect.Uint32:  reflect.TypeFor[uint32](),
+	reflect.Uint64:  reflect.TypeFor[uint64](),
+	reflect.Float32: reflect.TypeFor[float32](),
+	reflect.Float64: reflect.TypeFor[float64](),
+}
+
+var (
+	valueType           = reflect.TypeFor[flag.Value]()
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+	durationType        = reflect.TypeFor[time.Duration]()
+	timeType            = reflect.TypeFor[time.Time]()
+)
+
+// isCustomType reports whether t parses command line values itself, through
+// flag.Value or encoding.TextUnmarshaler.
+func isCustomType(t reflect.Type) bool {
+	p := reflect.PointerTo(t)
+	return p.Implements(valueType) || p.Implements(textUnmarshalerType)
+}
+
+// isScalarType reports whether a single command line value can be stored in
+// a t, either through its kind or by being a custom type.
+func isScalarType(t reflect.Type) bool {
+	if isCustomType(t) {
+		return true
+	}
+
+	_, ok := kindTypes[t.Kind()]
+	return ok
+}
+
+// newValue returns a flag.Value storing into the pointer p, or nil if the
+// pointed type is not supported.
+func newValue(p reflect.Value) flag.Value {
+	value := flag.NewValue(p.Interface())
+	if value != nil {
+		return value
+	}
+
+	// Named types such as `type Mode string` are stored through their
+	// underlying type.
+	t, ok := kindTypes[p.Type().Elem().Kind()]
+	if !ok {
+		return nil
+	}
+	return flag.NewValue(p.Convert(reflect.PointerTo(t)).Interface())
+}
+
+// isSliceType reports whether t accumulates repeated values into a slice.
+func isSliceType(t reflect.Type) bool {
+	return t.Kind() == reflect.Slice && !isCustomType(t) && isScalarType(t.Elem())
+}
+
+// isMapType reports whether t collects key=value pairs into a map.
+func isMapType(t reflect.Type) bool {
+	return t.Kind() == reflect.Map && !isCustomType(t) && t.Key().Kind() == reflect.String && isScalarType(t.Elem())
+}
+
+type valueFunc func(p reflect.Value) flag.Value
+
+// newValueFunc returns the function creating the values of the field,
+// honoring its layout tag and its choices.
+func newValueFunc(sf *structField) valueFunc {
+	newElem := newValue
+
+	if layout := sf.getLayout(); layout != "" {
+		newElem = func(p reflect.Value) flag.Value {
+			if t, ok := p.Interface().(*time.Time); ok {
+				return flag.NewTimeValue(t, layout)
+			}
+			return newValue(p)
+		}
+	}
+
+	choices := getChoices(sf)
+	if len(choices) == 0 {
+		return newElem
+	}
+
+	return func(p reflect.Value) flag.Value {
+		value := newElem(p)
+		if value == nil {
+			return nil
+		}
+		return choiceValue{Value: value, choices: choices}
+	}
+}
+
+// newFieldValue returns a flag.Value storing into the struct field v, or nil
+// if the field type is not supported.
+func newFieldValue(sf *structField, v reflect.Value) flag.Value {
+	newElem := newValueFunc(sf)
+
+	if v.Kind() == reflect.Pointer {
+		if v.Type().Implements(valueType) {
+			return pointerValue{ptr: v, newElem: newElem}
+		}
+
+		if newElem(reflect.New(v.Type().Elem())) == nil {
+			return nil
+		}
+		return optionalValue{ptr: v, newElem: newElem}
+	}
+
+	if isSliceType(v.Type()) {
+		return &sliceValue{slice: v, newElem: newElem, separator: sf.getSeparator()}
+	}
+
+	if isMapType(v.Type()) {
+		return &mapValue{m: v, newElem: newElem, separator: sf.getSeparator(), unique: sf.getDuplicate() == "error"}
+	}
+	return newElem(v.Addr())
+}
+
+// getDefaultValue returns the default of the field as shown in help and
+// whether it comes from a default tag. The tag is validated against the
+// field type, otherwise the initial value of the field is used unless it is
+// the zero value of its type.
+func getDefaultValue(sf *structField, value flag.Value) (string, bool, error) {
+	zero := newFieldValue(sf, reflect.New(sf.Type).Elem())
+
+	if def, ok := sf.getDefault(); ok {
+		err := zero.Set(def)
+		if err != nil {
+			return "", false, fmt.Errorf("invalid default value %q for field `%s`: %w", def, sf.Name, err)
+		}
+		return def, true, nil
+	}
+
+	def := value.String()
+	if def == zero.String() {
+		return "", false, nil
+	}
+	return def, false, nil
+}
+
+func isBoolValue(value flag.Value) bool {
+	bv, ok := value.(flag.BoolValue)
+	return ok && bv.IsBool()
+}
+
+// optionalValue allocates the pointer field only when a value is set.
+type optionalValue struct {
+	ptr     reflect.Value
+	newElem valueFunc
+}
+
+func (o optionalValue) String() string {
+	if o.ptr.IsNil() {
+		return ""
+	}
+	return o.newElem(o.ptr).String()
+}
+
+func (o optionalValue) Set(s string) error {
+	elem := reflect.New(o.ptr.Type().Elem())
+
+	err := o.newElem(elem).Set(s)
+	if err != nil {
+		return err
+	}
+
+	o.ptr.Set(elem)
+	return nil
+}
+
+func (o optionalValue) IsBool() bool {
+	return isBoolValue(o.newElem(reflect.New(o.ptr.Type().Elem())))
+}
+
+// pointerValue sets a pointer field whose type implements flag.Value. It is
+// allocated only if nil, so that every value is set on the same instance and
+// its initial state is kept.
+type pointerValue struct {
+	ptr     reflect.Value
+	newElem valueFunc
+}
+
+func (p pointerValue) String() string {
+	if p.ptr.IsNil() {
+		return ""
+	}
+	return p.newElem(p.ptr).String()
+}
+
+func (p pointerValue) Set(s string) error {
+	if !p.ptr.IsNil() {
+		return p.newElem(p.ptr).Set(s)
+	}
+
+	elem := reflect.New(p.ptr.Type().Elem())
+	err := p.newElem(elem).Set(s)
+	if err != nil {
+		return err
+	}
+
+	p.ptr.Set(elem)
+	return nil
+}
+
+func (p pointerValue) IsBool() bool {
+	if p.ptr.IsNil() {
+		return isBoolValue(p.newElem(reflect.New(p.ptr.Type().Elem())))
+	}
+	return isBoolValue(p.newElem(p.ptr))
+}
+
+// sliceValue appends every value set to the slice field, replacing the
+// initial content on the first one.
+type sliceValue struct {
+	slice     reflect.Value
+	newElem   valueFunc
+	separator string
+	changed   bool
+}
+
+// quoteElem quotes an element of a slice or map value that contains the
+// comma joining the elements, or a quote, so that elements can be told apart.
+func quoteElem(s string) string {
+	if strings.ContainsAny(s, `,"`) {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+func (s *sliceValue) String() string {
+	values := make([]string, 0, s.slice.Len())
+	for i := 0; i < s.slice.Len(); i++ {
+		values = append(values, quoteElem(s.newElem(s.slice.Index(i).Addr()).String()))
+	}
+	return strings.Join(values, ",")
+}
+
+func (s *sliceValue) Set(val string) error {
+	parts := []string{val}
+	if s.separator != "" {
+		parts = strings.Split(val, s.separator)
+	}
+
+	elems := reflect.MakeSlice(s.slice.Type(), len(parts), len(parts))
+	for i, part := range parts {
+		err := s.newElem(elems.Index(i).Addr()).Set(part)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !s.changed {
+		s.slice.Set(reflect.MakeSlice(s.slice.Type(), 0, len(parts)))
+		s.changed = true
+	}
+
+	s.slice.Set(reflect.AppendSlice(s.slice, elems))
+	return nil
+}
+
+// mapValue stores key=value pairs into the map field, replacing the initial
+// content on the first one. A repeated key overrides the previous value
+// unless unique is set.
+type mapValue struct {
+	m         reflect.Value
+	newElem   valueFunc
+	separator string
+	unique    bool
+	changed   bool
+}
+
+func (m *mapValue) String() string {
+	pairs := make([]string, 0, m.m.Len())
+	iter := m.m.MapRange()
+	for iter.Next() {
+		value := reflect.New(iter.Value().Type())
+		value.Elem().Set(iter.Value())
+		pairs = append(pairs, quoteElem(iter.Key().String()+"="+m.newElem(value).String()))
+	}
+
+	slices.Sort(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (m *mapValue) Set(val string) error {
+	parts := []string{val}
+	if m.separator != "" {
+		parts = strings.Split(val, m.separator)
+	}
+
+	if !m.changed {
+		m.m.Set(reflect.MakeMap(m.m.Type()))
+		m.changed = true
+	}
+
+	for _, part := range parts {
+		k, v, ok := strings.Cut(part, "=")
+		if !ok {
+			return fmt.Errorf("%q is not a key=value pair", part)
+		}
+
+		key := reflect.ValueOf(k).Convert(m.m.Type().Key())
+		if m.unique && m.m.MapIndex(key).IsValid() {
+			return fmt.Errorf("duplicated key %q", k)
+		}
+
+		value := reflect.New(m.m.Type().Elem())
+		err := m.newElem(value).Set(v)
+		if err != nil {
+			return err
+		}
+
+		m.m.SetMapIndex(key, value.Elem())
+	}
+	return nil
+}
+
+// negatedValue backs the --no-<name> form of a negatable boolean option.
+type negatedValue struct{ value flag.Value }
+
+func (n negatedValue) String() string { return "" }
+
+func (n negatedValue) Set(s string) error {
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return err
+	}
+	return n.value.Set(strconv.FormatBool(!b))
+}
+
+func (n negatedValue) IsBool() bool { return true }
+
+func (n negatedValue) IsSwitch() bool { return true }
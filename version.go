@@ -0,0 +1,98 @@
+package structcli
+
+import (
+	"errors"
+	"io"
+	"runtime/debug"
+	"strings"
+	"text/template"
+)
+
+// ErrVersion is returned by Parse and Execute after printing the version
+// requested with --version.
+var ErrVersion = errors.New("version requested")
+
+// DefaultVersionTemplate is the template used to print the version unless
+// another one is set with SetVersionTemplate.
+const DefaultVersionTemplate = "{{.Name}} {{.Version}}\n"
+
+// VersionInfo is the data the version template is executed with.
+type VersionInfo struct {
+	Name    string
+	Version string
+
+	// GoVersion, Revision, Time and Modified are read from the build
+	// information embedded in the binary, when available.
+	GoVersion string
+	Revision  string
+	Time      string
+	Modified  bool
+}
+
+func newVersionInfo(name, version string) *VersionInfo {
+	info := &VersionInfo{
+		Name:    name,
+		Version: version,
+	}
+
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+
+	info.GoVersion = bi.GoVersion
+	for _, setting := range bi.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			info.Revision = setting.Value
+		case "vcs.time":
+			info.Time = setting.Value
+		case "vcs.modified":
+			info.Modified = setting.Value == "true"
+		}
+	}
+	return info
+}
+
+func writeVersion(w io.Writer, tmpl *template.Template, info *VersionInfo) error {
+	var b strings.Builder
+
+	err := tmpl.Execute(&b, info)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+// registerVersion adds the built-in --version option, and -V if requested,
+// unless the command defines them itself.
+func (c *commandSpec) registerVersion(shorthand bool) {
+	c.versionShort = shorthand && c.optionsByName["-V"] == nil
+	c.versionLong = c.optionsByName["--version"] == nil
+	if !c.versionShort && !c.versionLong {
+		return
+	}
+
+	var short, long string
+	if c.versionShort {
+		short = "V"
+	}
+	if c.versionLong {
+		long = "version"
+	}
+
+	var requested bool
+	c.flags.BoolVar(&requested, false, short, long, "Print version")
+
+	if long != "" {
+		c.versionFlag = c.flags.Lookup(long)
+	} else {
+		c.versionFlag = c.flags.ShorthandLookup(short)
+	}
+}
+
+func (c *commandSpec) versionRequested() bool {
+	return c.versionFlag != nil && c.versionFlag.Changed
+}